@@ -0,0 +1,54 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"github.com/digitalocean/godo"
+	"time"
+)
+
+// Used to wait for an action to complete. Transient errors are retried since this is ran during teardown.
+func waitForAction(get func() (*godo.Action, *godo.Response, error)) error {
+	for {
+		time.Sleep(2 * time.Second)
+		a, resp, err := get()
+		if err != nil {
+			if resp != nil && (resp.StatusCode == 401 || resp.StatusCode == 404) {
+				return err
+			}
+			logger.Warnf("Failed to get the action status. Will try again: %v", err)
+			continue
+		}
+		switch a.Status {
+		case godo.ActionCompleted:
+			return nil
+		case "errored":
+			return errors.New(a.Type + " action errored")
+		}
+	}
+}
+
+// Used to wait for a droplet action to complete.
+func waitForDropletAction(dropletID, actionID int) error {
+	return waitForAction(func() (*godo.Action, *godo.Response, error) {
+		return client.DropletActions.Get(context(), dropletID, actionID)
+	})
+}
+
+// Used to wait for a volume action to complete.
+func waitForVolumeAction(volumeID string, actionID int) error {
+	return waitForAction(func() (*godo.Action, *godo.Response, error) {
+		return client.StorageActions.Get(context(), volumeID, actionID)
+	})
+}
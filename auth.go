@@ -31,13 +31,19 @@ func (*authCmd) Usage() string {
 func (p *authCmd) SetFlags(_ *flag.FlagSet) {}
 
 func (p *authCmd) Execute(_ c.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	fp, exists := loadConfig()
-	if exists {
-		config.Token = setToken()
-		writeConfig(fp)
-		genSSHKey()
-	} else {
-		inputSaveConfig(fp)
+	fp, exists, err := loadConfig()
+	if err != nil {
+		return exitStatus(err)
 	}
-	return subcommands.ExitSuccess
+	if !exists {
+		return exitStatus(inputSaveConfig(fp))
+	}
+	config.Token, err = setToken()
+	if err == nil {
+		err = genSSHKey()
+	}
+	if err == nil {
+		err = writeConfig(fp)
+	}
+	return exitStatus(err)
 }
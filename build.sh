@@ -1,59 +1,83 @@
 #!/usr/bin/env bash
 # Heavily based off https://www.digitalocean.com/community/tutorials/how-to-build-go-executables-for-multiple-platforms-on-ubuntu-16-04
+# Pass --slim to build do-disposable with only the digests embedded, so that the droplet tools are downloaded on the droplet by droplet_init.sh instead of uploaded.
 
-# 1) Build the do-disposable binary
+slim=false
+if [ "$1" = "--slim" ]; then
+    slim=true
+fi
 
-mkdir cli-dist
+# 1) Build the copyback/copyfrom binaries
+# These are built first since they are embedded into the do-disposable binary (see droplet-tools/README.md).
 
-cp do-disposable-install.ps1 cli-dist/do-disposable-install.ps1
-cp do-disposable-install.sh cli-dist/do-disposable-install.sh
+platforms=("linux/amd64" "linux/arm64")
 
-platforms=("windows/amd64" "windows/386" "windows/arm" "freebsd/amd64" "freebsd/386" "freebsd/arm" "linux/amd64" "linux/386" "linux/arm" "linux/arm64" "darwin/amd64")
+mkdir droplet-tools-dist
 
 for platform in "${platforms[@]}"
 do
     platform_split=(${platform//\// })
     GOOS=${platform_split[0]}
     GOARCH=${platform_split[1]}
-    output_name='./cli-dist/do-disposable_'$GOOS'-'$GOARCH
-    if [ $GOOS = "windows" ]; then
-        output_name+='.exe'
-    fi
 
-    env GOOS=$GOOS GOARCH=$GOARCH go build -o $output_name .
-    if [ $? -ne 0 ]; then
-        echo 'An error has occurred! Aborting the script execution...'
-        exit 1
-    fi
+    for tool in copyback copyfrom
+    do
+        output_name='../droplet-tools/'$tool'_'$GOOS'_'$GOARCH
+
+        cd $tool
+        env GOOS=$GOOS GOARCH=$GOARCH go build -ldflags "-s -w" -o $output_name .
+        if [ $? -ne 0 ]; then
+            echo 'An error has occurred! Aborting the script execution...'
+            exit 1
+        fi
+        cd ..
+
+        # The binaries are also published for the droplet_init.sh fallback.
+        # The amd64 binaries are additionally published under the names used by older releases.
+        cp droplet-tools/$tool'_'$GOOS'_'$GOARCH droplet-tools-dist/$tool'_'$GOOS'_'$GOARCH
+        if [ $GOARCH = "amd64" ]; then
+            cp droplet-tools/$tool'_'$GOOS'_'$GOARCH droplet-tools-dist/$tool'_'$GOOS
+        fi
+    done
 done
 
-# 2) Build the copyback/copyfrom binaries
+# 2) Publish the SHA-256 digests of the droplet tools
+# The manifest is embedded into do-disposable so that binaries downloaded by droplet_init.sh are checked against pinned digests.
 
-platforms=("freebsd/amd64" "linux/amd64")
+cp droplet_init.sh droplet-tools-dist/droplet_init.sh
+(cd droplet-tools-dist && sha256sum copyback_* copyfrom_* > SHA256SUMS)
+if [ $? -ne 0 ]; then
+    echo 'An error has occurred! Aborting the script execution...'
+    exit 1
+fi
+cp droplet-tools-dist/SHA256SUMS droplet-tools/SHA256SUMS
 
-mkdir droplet-tools-dist
+if [ $slim = true ]; then
+    rm -f droplet-tools/copy*
+fi
+
+# 3) Build the do-disposable binary
+
+mkdir cli-dist
+
+cp do-disposable-install.ps1 cli-dist/do-disposable-install.ps1
+cp do-disposable-install.sh cli-dist/do-disposable-install.sh
+
+platforms=("windows/amd64" "windows/386" "windows/arm" "freebsd/amd64" "freebsd/386" "freebsd/arm" "linux/amd64" "linux/386" "linux/arm" "linux/arm64" "darwin/amd64")
 
 for platform in "${platforms[@]}"
 do
     platform_split=(${platform//\// })
     GOOS=${platform_split[0]}
     GOARCH=${platform_split[1]}
-    output_name='../droplet-tools-dist/copyback_'$GOOS
-
-    cd copyback
-    env GOOS=$GOOS GOARCH=$GOARCH go build -o $output_name .
-    if [ $? -ne 0 ]; then
-        echo 'An error has occurred! Aborting the script execution...'
-        exit 1
+    output_name='./cli-dist/do-disposable_'$GOOS'-'$GOARCH
+    if [ $GOOS = "windows" ]; then
+        output_name+='.exe'
     fi
-    cd ..
 
-    output_name='../droplet-tools-dist/copyfrom_'$GOOS
-    cd copyback
     env GOOS=$GOOS GOARCH=$GOARCH go build -o $output_name .
     if [ $? -ne 0 ]; then
         echo 'An error has occurred! Aborting the script execution...'
         exit 1
     fi
-    cd ..
 done
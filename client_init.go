@@ -16,10 +16,12 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"github.com/digitalocean/godo"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/ssh"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -28,32 +30,37 @@ import (
 // Used to define the DO client.
 var client *godo.Client
 
+// Used to create the DigitalOcean client for a token. API requests are logged under -debug with the token redacted.
+func newClient(token string) *godo.Client {
+	logger.addSecret(token)
+	return godo.NewClient(&http.Client{Transport: &apiTransport{token: token, base: http.DefaultTransport}})
+}
+
 // Used to get the user to input their token.
-func setToken() string {
+func setToken() (string, error) {
 	for {
 		text := GetInput("What is your DigitalOcean token? You will need a read/write API key which you can generate from the \"API Keys\" panel when you are signed in with your DigitalOcean account: ")
 		if text == "" {
 			continue
 		}
-		client = godo.NewFromToken(text)
+		client = newClient(text)
 		_, resp, err := client.Tags.List(context(), &godo.ListOptions{})
 		if err == nil {
-			return text
+			return text, nil
 		}
 		if resp != nil && resp.StatusCode == 401 {
 			continue
 		}
-		panic(err)
+		return "", classifyError(err)
 	}
 }
 
 // Tries to load the config. Returns false if requires init.
-func loadConfig() (string, bool) {
+func loadConfig() (string, bool, error) {
 	homedir, err := os.UserHomeDir()
 	if err != nil {
 		// For every platform we support, we expect a home folder to exist.
-		// There's not much we can do here except crash.
-		panic(err)
+		return "", false, err
 	}
 	fp := filepath.Join(homedir, ".do-disposable")
 	f, err := os.Open(fp)
@@ -63,24 +70,26 @@ func loadConfig() (string, bool) {
 		// Attempt to decode the Gob file.
 		decoder := gob.NewDecoder(f)
 		err = decoder.Decode(&config)
+		_ = f.Close()
 		if err != nil {
 			// The user has either manually tried to modify this or something went wrong in the application.
-			panic(err)
+			return fp, false, configCorruptError(fp, err)
 		}
 
 		// Create the client with this token.
-		client = godo.NewFromToken(config.Token)
+		logger.Debugf("Loaded the config from %s.", fp)
+		client = newClient(config.Token)
 
 		// Return true here.
-		return fp, true
+		return fp, true, nil
 	}
 
 	if os.IsNotExist(err) {
 		// The file doesn't exist. Return false.
-		return fp, false
+		return fp, false, nil
 	} else {
-		// The error here is a configuration issue with the users system. We will crash.
-		panic(err)
+		// The error here is a configuration issue with the users system.
+		return fp, false, configCorruptError(fp, err)
 	}
 }
 
@@ -91,53 +100,58 @@ func getTag(x string) string {
 }
 
 // Used to write the config.
-func writeConfig(fp string) {
+func writeConfig(fp string) error {
 	f, err := os.Create(fp)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	e := gob.NewEncoder(f)
 	err = e.Encode(config)
+	closeErr := f.Close()
 	if err != nil {
-		panic(err)
+		return err
 	}
+	return closeErr
 }
 
 // Used to set the default region.
-func setDefaultRegion() []godo.Region {
+func setDefaultRegion() ([]godo.Region, error) {
 	regions, _, err := client.Regions.List(context(), &godo.ListOptions{})
 	if err != nil {
 		// Hmmmmm this is odd.
-		panic(err)
+		return nil, classifyError(err)
 	}
-	descs := make([]string, len(regions))
+	descs := make([]string, 0, len(regions))
 	nyc3Exists := false
-	for i, v := range regions {
+	for _, v := range regions {
 		if !v.Available {
 			// Not relevant to us. Continue.
 			continue
 		}
-		descs[i] = v.Name + " [" + v.Slug + "]"
+		descs = append(descs, v.Name+" ["+v.Slug+"]")
 		if v.Slug == "nyc3" {
 			nyc3Exists = true
 		}
 	}
+	if len(descs) == 0 {
+		return nil, regionError(errors.New("there are no regions available to your account"))
+	}
 	DefaultRegion := "New York 3 [nyc3]"
 	if !nyc3Exists {
 		DefaultRegion = descs[0]
 	}
 	config.DefaultRegion = getTag(FormatList("What's the default region you wish to use?", descs, &DefaultRegion))
-	return regions
+	return regions, nil
 }
 
 // Used to set the droplet size.
-func setSize(regions []godo.Region) {
+func setSize(regions []godo.Region) error {
 	var err error
 	if regions == nil {
 		regions, _, err = client.Regions.List(context(), &godo.ListOptions{})
 		if err != nil {
 			// Hmmmmm this is odd.
-			panic(err)
+			return classifyError(err)
 		}
 	}
 	var sizes []string
@@ -150,7 +164,7 @@ func setSize(regions []godo.Region) {
 	doGlobalSizes, _, err := client.Sizes.List(context(), &godo.ListOptions{})
 	if err != nil {
 		// Very odd.
-		panic(err)
+		return classifyError(err)
 	}
 	for _, v := range doGlobalSizes {
 		if !v.Available {
@@ -167,74 +181,101 @@ func setSize(regions []godo.Region) {
 		}
 		dropletDescs = append(dropletDescs, fmt.Sprintf("%d GB storage/%d vCPUS/%d MB RAM/$%f per hour [%s]", x.Disk, x.Vcpus, x.Memory, x.PriceHourly, v))
 	}
+	if len(dropletDescs) == 0 {
+		return regionError(errors.New("there are no droplet sizes available in " + config.DefaultRegion))
+	}
 	config.DefaultSize = getTag(FormatList("What's the default droplet size you wish to use?", dropletDescs, &dropletDescs[0]))
+	return nil
 }
 
 // Used to get the public SSH key of the user.
-func getPublicKey() string {
+func getPublicKey() (string, error) {
 	pub, err := ssh.NewPublicKey(&config.PrivateKey.PublicKey)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
-	return string(ssh.MarshalAuthorizedKey(pub))
+	return string(ssh.MarshalAuthorizedKey(pub)), nil
 }
 
 // Used to generate/upload the SSH key.
-func genSSHKey() {
+func genSSHKey() error {
 	print("Generating application specific SSH key... ")
 	var err error
 	config.PrivateKey, err = rsa.GenerateKey(rand.Reader, 4096)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	err = config.PrivateKey.Validate()
 	if err != nil {
-		panic(err)
+		return err
 	}
 	println("done!")
 
 	// Save the SSH key to the user.
 	print("Saving application specific SSH key to user... ")
+	pub, err := getPublicKey()
+	if err != nil {
+		return err
+	}
 	info, _, err := client.Keys.Create(context(), &godo.KeyCreateRequest{
 		Name:      "do-disposable ["+uuid.New().String()+"]",
-		PublicKey: getPublicKey(),
+		PublicKey: pub,
 	})
 	if err != nil {
-		panic(err)
+		println("failed!")
+		return classifyError(err)
 	}
 	config.KeyID = info.ID
 	println("done!")
+	return nil
 }
 
 // Used to get the user to input their config options and then save it as a new config.
-func inputSaveConfig(fp string) {
+func inputSaveConfig(fp string) error {
 	// Create the base config structure.
 	config = &configStructure{}
 
 	// Set the users token.
-	config.Token = setToken()
+	var err error
+	config.Token, err = setToken()
+	if err != nil {
+		return err
+	}
 
 	// Create the client with this token.
-	client = godo.NewFromToken(config.Token)
+	client = newClient(config.Token)
 
 	// Get the default region from the user.
-	regions := setDefaultRegion()
+	regions, err := setDefaultRegion()
+	if err != nil {
+		return err
+	}
 
 	// Get the default droplet size from the user.
-	setSize(regions)
+	if err = setSize(regions); err != nil {
+		return err
+	}
 
 	// Generate the SSH key.
-	genSSHKey()
-	
+	if err = genSSHKey(); err != nil {
+		return err
+	}
+
 	// Write the config.
-	writeConfig(fp)
+	return writeConfig(fp)
 }
 
-// Used to initialise the DigitalOcean client and config.
-func clientInit() {
-	_, exists := loadConfig()
+// Used when the config needs to exist.
+var errNoConfig = authError(errors.New("the configuration is not set"))
+
+// Used to initialise the DigitalOcean client and config. Returns the path of the config.
+func clientInit() (string, error) {
+	fp, exists, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
 	if !exists {
-		println("Configuration is not set. Please run do-disposable auth.")
-		os.Exit(1)
+		return "", errNoConfig
 	}
+	return fp, nil
 }
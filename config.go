@@ -20,6 +20,13 @@ type configStructure struct {
 	DefaultSize string
 	PrivateKey *rsa.PrivateKey
 	KeyID int
+	DefaultVPC string
+	DefaultTags []string
+	Monitoring bool
+	NoFirewall bool
+	DefaultProject string
+	Sync syncConfig
+	SyncTemplates map[string]*syncConfig
 }
 
 var config *configStructure
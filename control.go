@@ -0,0 +1,204 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/gob"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Defines the first message sent over the control socket to open a shell.
+type shellRequest struct {
+	Term   string
+	Width  int
+	Height int
+}
+
+// Defines the messages sent over the control socket after the request. Only the fields for the message type are set.
+type controlMessage struct {
+	// Sent in both directions.
+	Data []byte
+
+	// Sent to the up process.
+	Width  int
+	Height int
+	Signal string
+
+	// Sent to the shell process when the session ends.
+	Exit       bool
+	ExitStatus int
+}
+
+// Used to get the folder which the control sockets are kept in. Only the user can access it.
+func controlSocketDir() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(homedir, ".do-disposable-sockets")
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	return dir, os.Chmod(dir, 0700)
+}
+
+// Used to get the running droplets which have a control socket. Sockets left behind by processes which crashed are removed.
+func runningDroplets() (map[string]string, error) {
+	dir, err := controlSocketDir()
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.sock"))
+	if err != nil {
+		return nil, err
+	}
+	droplets := map[string]string{}
+	for _, v := range matches {
+		conn, err := net.Dial("unix", v)
+		if err != nil {
+			_ = os.Remove(v)
+			continue
+		}
+		_ = conn.Close()
+		droplets[strings.TrimSuffix(filepath.Base(v), ".sock")] = v
+	}
+	return droplets, nil
+}
+
+// Used to write control messages from multiple goroutines.
+type controlWriter struct {
+	mu  sync.Mutex
+	enc *gob.Encoder
+}
+
+func (w *controlWriter) send(m *controlMessage) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(m)
+}
+
+// Used to write session output as data messages.
+func (w *controlWriter) Write(b []byte) (int, error) {
+	err := w.send(&controlMessage{Data: b})
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Used to open a new session on the droplet for a shell process and pass everything through until either side ends.
+func handleControlConn(sshClient *ssh.Client, conn net.Conn, env map[string]string) error {
+	defer conn.Close()
+	dec := gob.NewDecoder(conn)
+	w := &controlWriter{enc: gob.NewEncoder(conn)}
+	var req shellRequest
+	err := dec.Decode(&req)
+	if err != nil {
+		return err
+	}
+
+	// Create the session.
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdout = w
+	session.Stderr = w
+	setSessionEnv(session, env)
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	err = session.RequestPty(req.Term, req.Height, req.Width, ssh.TerminalModes{
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	})
+	if err != nil {
+		return err
+	}
+	err = session.Shell()
+	if err != nil {
+		return err
+	}
+
+	// Handle the messages from the shell process. If it goes away, close the session.
+	go func() {
+		for {
+			var m controlMessage
+			if err := dec.Decode(&m); err != nil {
+				_ = session.Close()
+				return
+			}
+			if m.Data != nil {
+				_, _ = stdin.Write(m.Data)
+			}
+			if m.Width != 0 && m.Height != 0 {
+				_ = session.WindowChange(m.Height, m.Width)
+			}
+			if m.Signal != "" {
+				_ = session.Signal(ssh.Signal(m.Signal))
+			}
+		}
+	}()
+
+	// Wait for the session to end and pass through the exit status.
+	status := 0
+	err = session.Wait()
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		status = exitErr.ExitStatus()
+	} else if err != nil {
+		status = 255
+	}
+	return w.send(&controlMessage{Exit: true, ExitStatus: status})
+}
+
+// Used to start the control socket which lets do-disposable shell open more sessions over the SSH connection.
+// Any variables which the SSH server refuses are in the secrets file already. Returns a function which closes and removes the socket.
+func serveControl(sshClient *ssh.Client, name string, env map[string]string) (func(), error) {
+	dir, err := controlSocketDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, name+".sock")
+	_ = os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				if err := handleControlConn(sshClient, conn, env); err != nil && !errors.Is(err, io.EOF) {
+					logger.Warnf("Failed to open a shell for do-disposable shell: %v", err)
+				}
+			}()
+		}
+	}()
+	return func() {
+		_ = ln.Close()
+		_ = os.Remove(path)
+	}, nil
+}
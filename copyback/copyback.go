@@ -15,39 +15,78 @@ package main
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"flag"
+	"github.com/do-community/do-disposable/internal/copytool"
 	"github.com/jakemakesstuff/structuredhttp"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"syscall"
+	"time"
 )
 
+// Defines the command line flags.
+var (
+	quiet          = flag.Bool("quiet", false, "Disables the progress output.")
+	progressFormat = flag.String("progress", "", "Sets the progress output format. Set to json for one JSON object per line for wrapping tools.")
+	owner          = flag.Bool("owner", false, "Preserves the uid/gid of the files. This requires the host to be running as root.")
+)
+
+// The data used to initialise a transfer. This matches the copyserver.
+type transferInit struct {
+	LocalPath string
+	TotalBytes uint
+	Perm os.FileMode
+	Stream bool
+	Type string
+	LinkTarget string
+	ModTime time.Time
+	HasOwner bool
+	Uid int
+	Gid int
+}
+
+// Used to track the progress of the copy.
+var prog *copytool.Progress
+
+// Defines the include/exclude filters.
+var filter copytool.Filter
+
 // Used to gracefully error the application.
 func gracefulError(message string) {
 	println(message)
 	os.Exit(1)
 }
 
-// Used to handle the transfer to the host.
-func transferToHost(hostRelPath string, r io.Reader, len uint, perm os.FileMode) {
-	// Initialise the transfer.
-	type transferInit struct {
-		LocalPath string
-		TotalBytes uint
-		Perm os.FileMode
+// Defines the exit code used when the host can't be reached. This matches the network exit code of do-disposable.
+const exitNetwork = 6
+
+// Used to exit on an error. Errors reaching the host get their own exit code so that scripts can retry them.
+func fatalError(err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		println("unable to reach do-disposable on the host (is do-disposable up still running?): " + err.Error())
+		os.Exit(exitNetwork)
 	}
+	println(err.Error())
+	os.Exit(1)
+}
+
+// Used to handle the transfer to the host. If the transfer is a stream, the length is unknown and the reader is read until EOF.
+// Folders and symlinks have no contents so the reader is nil for them.
+func transferToHost(data *transferInit, r io.Reader) {
+	// Initialise the transfer.
 	buf := &bytes.Buffer{}
 	encoder := gob.NewEncoder(buf)
-	err := encoder.Encode(&transferInit{
-		LocalPath:  hostRelPath,
-		TotalBytes: len,
-		Perm:       perm,
-	})
+	err := encoder.Encode(data)
 	if err != nil {
-		panic(err)
+		fatalError(err)
 	}
 	resp, err := structuredhttp.POST("http://127.0.0.1:8190/v1/StartTransferSession").Reader(buf).Run()
 	if err != nil {
-		panic(err)
+		fatalError(err)
 	}
 	err = resp.RaiseForStatus()
 	if err != nil {
@@ -55,112 +94,243 @@ func transferToHost(hostRelPath string, r io.Reader, len uint, perm os.FileMode)
 	}
 	transferId, err := resp.Text()
 	if err != nil {
-		panic(err)
+		fatalError(err)
+	}
+	if r == nil {
+		return
 	}
+	stream := data.Stream
+
+	// Track the progress of the file.
+	total := int64(data.TotalBytes)
+	if stream {
+		total = -1
+	}
+	prog.StartFile(data.LocalPath, total)
+	defer prog.EndFile()
+	r = prog.Reader(r)
 
 	// Chunk the transfer into 1MB blocks.
 	block := make([]byte, 1000000)
 	for {
 		// Read 1MB maximum.
-		n, err := r.Read(block)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			panic(err)
+		n, readErr := io.ReadFull(r, block)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			fatalError(readErr)
+		}
+		eof := readErr != nil
+		if n == 0 && !(eof && stream) {
+			break
 		}
 		resizedBlock := block[:n]
 
-		// Upload the chunk.
-		resp, err := structuredhttp.POST("http://127.0.0.1:8190/v1/HandleFragment").Bytes(resizedBlock).Header("Transfer-ID", transferId).Run()
+		// Upload the chunk. The last chunk of a stream tells the host that the transfer is done.
+		req := structuredhttp.POST("http://127.0.0.1:8190/v1/HandleFragment").Bytes(resizedBlock).Header("Transfer-ID", transferId)
+		if eof && stream {
+			req = req.Header("Transfer-End", "true")
+		}
+		resp, err := req.Run()
 		if err != nil {
-			panic(err)
+			fatalError(err)
 		}
 		err = resp.RaiseForStatus()
 		if err != nil {
 			gracefulError(func() (text string) { text, _ = resp.Text(); return }())
 		}
+		if eof {
+			break
+		}
 	}
 }
 
 // Shows the command usage.
 func usage()  {
-	println("copyback - copy a file/folder back from the droplet")
-	println("usage: copyback <droplet file/folder path> [host save location]")
+	println("copyback - copy files/folders back from the droplet")
+	println("usage: copyback [flags] <droplet file/folder path or glob>... [host save location]")
+	println("use - as the droplet path to copy stdin to the host")
+	flag.PrintDefaults()
 	os.Exit(0)
 }
 
-// Used to process a file.
+// Used to process a file, folder or symlink.
 func processFile(s os.FileInfo, dropletAbsPath, hostRelPath string) {
-	// Get the size for later.
-	size := s.Size()
+	// Get the metadata.
+	data := &transferInit{
+		LocalPath: hostRelPath,
+		Perm:      s.Mode().Perm(),
+		Type:      "file",
+		ModTime:   s.ModTime(),
+	}
+	if st, ok := s.Sys().(*syscall.Stat_t); ok && *owner {
+		data.HasOwner = true
+		data.Uid = int(st.Uid)
+		data.Gid = int(st.Gid)
+	}
+
+	// Handle folders and symlinks.
+	if s.IsDir() {
+		data.Type = "dir"
+		transferToHost(data, nil)
+		return
+	}
+	if s.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(dropletAbsPath)
+		if err != nil {
+			fatalError(err)
+		}
+		data.Type = "symlink"
+		data.LinkTarget = target
+		transferToHost(data, nil)
+		return
+	}
 
 	// Create a reader for the file.
 	r, err := os.Open(dropletAbsPath)
 	if err != nil {
-		panic(err)
+		fatalError(err)
 	}
 	defer r.Close()
 
 	// Upload the file.
-	transferToHost(hostRelPath, r, uint(size), s.Mode())
+	data.TotalBytes = uint(s.Size())
+	transferToHost(data, r)
+}
+
+// Used to walk the files, folders and symlinks within a source which pass the include/exclude filters. Symlinks are not followed.
+func walkSource(dropletAbsPath string, fn func(path, rel string, s os.FileInfo) error) error {
+	return filepath.Walk(dropletAbsPath, func(path string, s os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dropletAbsPath, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && !filter.Included(rel, s.IsDir()) {
+			if s.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return fn(path, rel, s)
+	})
 }
 
 // The main function.
 func main() {
-	// Check the arg count.
-	if len(os.Args) == 1 {
+	// Parse the flags and check the arg count.
+	flag.Var(&filter.Includes, "include", "Only copies files which match this pattern. Can be specified multiple times.")
+	flag.Var(&filter.Excludes, "exclude", "Skips files and folders which match this pattern. Can be specified multiple times.")
+	flag.Usage = usage
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
 		usage()
 	}
 
-	// Get the droplet path.
-	dropletPath := os.Args[1]
-	if dropletPath == "-h" {
-		usage()
+	// Get the droplet sources and the host path. If there is more than one argument, the last one is the host path.
+	sources := args
+	hostRelPath := ""
+	if len(args) > 1 {
+		sources = args[:len(args)-1]
+		hostRelPath = args[len(args)-1]
 	}
 
-	// Stat the droplet file.
-	dropletAbsPath, err := filepath.Abs(dropletPath)
-	if err != nil {
-		gracefulError(err.Error())
+	// Handle copying stdin to the host.
+	if hostRelPath == "-" {
+		gracefulError("- can only be used for the droplet side of the copy")
 	}
-
-	// Check if the file exists.
-	s, err := os.Stat(dropletAbsPath)
-	if err != nil {
-		gracefulError(err.Error())
+	if len(sources) == 1 && sources[0] == "-" {
+		if hostRelPath == "" {
+			gracefulError("a host save location is required when copying stdin")
+		}
+		prog = copytool.NewProgress(*quiet, *progressFormat, -1, os.Stdout)
+		transferToHost(&transferInit{
+			LocalPath: hostRelPath,
+			Perm:      0644,
+			Stream:    true,
+			ModTime:   time.Now(),
+		}, os.Stdin)
 		return
 	}
 
-	// Get the host relative path.
-	var hostRelPath string
-	if len(os.Args) > 2 {
-		hostRelPath = os.Args[2]
-	} else {
-		if filepath.Base(dropletAbsPath) == "." {
-			hostRelPath = "."
+	// Expand the sources and filter them.
+	var dropletAbsPaths []string
+	var stats []os.FileInfo
+	for _, v := range sources {
+		matches, err := filepath.Glob(v)
+		if err != nil {
+			gracefulError(err.Error())
+		}
+		if len(matches) == 0 {
+			gracefulError("no matches for " + v)
+		}
+		for _, m := range matches {
+			dropletAbsPath, err := filepath.Abs(m)
+			if err != nil {
+				gracefulError(err.Error())
+			}
+			s, err := os.Stat(dropletAbsPath)
+			if err != nil {
+				gracefulError(err.Error())
+			}
+			if !filter.Included(filepath.Base(dropletAbsPath), s.IsDir()) {
+				continue
+			}
+			dropletAbsPaths = append(dropletAbsPaths, dropletAbsPath)
+			stats = append(stats, s)
+		}
+	}
+	if len(dropletAbsPaths) == 0 {
+		gracefulError("no files to copy")
+	}
+
+	// Get the host path of each source. A single source is saved to the host path, multiple sources are saved within it.
+	hostRelPaths := make([]string, len(dropletAbsPaths))
+	for i, v := range dropletAbsPaths {
+		if len(dropletAbsPaths) == 1 && hostRelPath != "" {
+			hostRelPaths[i] = hostRelPath
+		} else if hostRelPath == "" {
+			hostRelPaths[i] = "./"+filepath.Base(v)
 		} else {
-			hostRelPath = "./"+filepath.Base(dropletAbsPath)
+			hostRelPaths[i] = filepath.Join(hostRelPath, filepath.Base(v))
 		}
 	}
 
-	// Check if this is a folder.
-	if s.IsDir() {
-		err := filepath.Walk(dropletAbsPath, func(path string, s os.FileInfo, err error) error {
-			if s.IsDir() {
-				return nil
-			}
-			diff, err := filepath.Rel(dropletAbsPath, path)
-			if err != nil {
-				return err
+	// Get the total size for the progress.
+	var total int64
+	for _, v := range dropletAbsPaths {
+		_ = walkSource(v, func(_, _ string, s os.FileInfo) error {
+			if s.Mode().IsRegular() {
+				total += s.Size()
 			}
-			processFile(s, path, filepath.Join(hostRelPath, diff))
 			return nil
 		})
-		if err != nil {
-			panic(err)
+	}
+	prog = copytool.NewProgress(*quiet, *progressFormat, total, os.Stdout)
+
+	// Copy each source.
+	for i, dropletAbsPath := range dropletAbsPaths {
+		if stats[i].IsDir() {
+			// Folders are sent after their contents (deepest first) so that empty folders are created and their mtimes aren't changed by the files written within them.
+			var dirs []func()
+			err := walkSource(dropletAbsPath, func(path, rel string, s os.FileInfo) error {
+				hostPath := filepath.Join(hostRelPaths[i], rel)
+				if s.IsDir() {
+					dirs = append(dirs, func() { processFile(s, path, hostPath) })
+				} else {
+					processFile(s, path, hostPath)
+				}
+				return nil
+			})
+			if err != nil {
+				fatalError(err)
+			}
+			for j := len(dirs) - 1; j >= 0; j-- {
+				dirs[j]()
+			}
+		} else {
+			processFile(stats[i], dropletAbsPath, hostRelPaths[i])
 		}
-	} else {
-		processFile(s, dropletAbsPath, hostRelPath)
 	}
 }
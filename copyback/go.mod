@@ -2,4 +2,9 @@ module github.com/do-community/do-disposable/copyback
 
 go 1.14
 
-require github.com/jakemakesstuff/structuredhttp v0.0.0-20200614104234-f8e4b2aebe68
+require (
+	github.com/do-community/do-disposable/internal/copytool v0.0.0-00010101000000-000000000000
+	github.com/jakemakesstuff/structuredhttp v0.0.0-20200614104234-f8e4b2aebe68
+)
+
+replace github.com/do-community/do-disposable/internal/copytool => ../internal/copytool
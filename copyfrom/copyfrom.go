@@ -15,122 +15,317 @@ package main
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"flag"
+	"github.com/do-community/do-disposable/internal/copytool"
 	"github.com/jakemakesstuff/structuredhttp"
+	"golang.org/x/sys/unix"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
-	"syscall"
+	"time"
 )
 
+// Defines the command line flags.
+var (
+	quiet          = flag.Bool("quiet", false, "Disables the progress output.")
+	progressFormat = flag.String("progress", "", "Sets the progress output format. Set to json for one JSON object per line for wrapping tools.")
+	owner          = flag.Bool("owner", false, "Preserves the uid/gid of the files. This requires copyfrom to be ran as root.")
+)
+
+// Defines the exit code used when the host can't be reached. This matches the network exit code of do-disposable.
+const exitNetwork = 6
+
+// Used to exit on an error. Errors reaching the host get their own exit code so that scripts can retry them.
+func fatalError(err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		println("unable to reach do-disposable on the host (is do-disposable up still running?): " + err.Error())
+		os.Exit(exitNetwork)
+	}
+	println(err.Error())
+	os.Exit(1)
+}
+
+// Used to apply the modification time and owner to a copied item. Ownership is best effort since it requires root.
+func applyMetadata(path string, modTime time.Time, hasOwner bool, uid, gid int) {
+	if !modTime.IsZero() {
+		_ = os.Chtimes(path, modTime, modTime)
+	}
+	if hasOwner && *owner {
+		_ = os.Lchown(path, uid, gid)
+	}
+}
+
+// Used to apply the modification time and owner to a recreated symlink without following it.
+func applySymlinkMetadata(path string, modTime time.Time, hasOwner bool, uid, gid int) {
+	if !modTime.IsZero() {
+		tv := unix.NsecToTimeval(modTime.UnixNano())
+		_ = unix.Lutimes(path, []unix.Timeval{tv, tv})
+	}
+	if hasOwner && *owner {
+		_ = os.Lchown(path, uid, gid)
+	}
+}
+
 // Shows the command usage.
 func usage()  {
-	println("copyfrom - copy a file/folder from the host")
-	println("usage: copyfrom <host file/folder path> [droplet save location]")
+	println("copyfrom - copy files/folders from the host")
+	println("usage: copyfrom [flags] <host file/folder path or glob>... [droplet save location]")
+	println("use - as the droplet save location to copy a host file to stdout")
+	flag.PrintDefaults()
 	os.Exit(0)
 }
 
-// The main function.
-func main() {
-	// Check the arg count.
-	if len(os.Args) == 1 {
-		usage()
-	}
+// Defines a host path which matched a source.
+type globMatch struct {
+	Path string
+	Size int64
+	IsDir bool
+}
 
-	// Get the host path.
-	hostRelPath := os.Args[1]
-	if hostRelPath == "-h" {
-		usage()
+// Used to expand a source on the host. Globs are expanded on the host since that is where the files live.
+func globHost(pattern string) []globMatch {
+	resp, err := structuredhttp.GET("http://127.0.0.1:8190/v1/GlobHost").Query("pattern", pattern).Run()
+	if err != nil {
+		fatalError(err)
+	}
+	if resp.RaiseForStatus() != nil {
+		t, _ := resp.Text()
+		println(t)
+		os.Exit(1)
+	}
+	b, err := resp.Bytes()
+	if err != nil {
+		fatalError(err)
+	}
+	var matches []globMatch
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&matches)
+	if err != nil {
+		fatalError(err)
 	}
+	return matches
+}
 
-	// Get the droplet path.
-	var dropletPath string
-	var err error
-	if len(os.Args) > 2 {
-		dropletPath, err = filepath.Abs(os.Args[2])
-		if err != nil {
-			panic(err)
+// Used to track the progress of the copy.
+var prog *copytool.Progress
+
+// Defines the include/exclude filters.
+var filter copytool.Filter
+
+// Used to handle copying a file or folder from the host. The path relative to the source is used for the include/exclude filters.
+func handleFileFolder(hostRelPath, dropletPath, rel string) {
+	resp, err := structuredhttp.GET("http://127.0.0.1:8190/v1/GetHost").Query("path", hostRelPath).Run()
+	if err != nil {
+		fatalError(err)
+	}
+	if resp.RaiseForStatus() != nil {
+		t, _ := resp.Text()
+		println(t)
+		os.Exit(1)
+	}
+	if resp.RawResponse.Header.Get("Is-Folder") == "true" {
+		// Get the folder information.
+		type folderEntry struct {
+			Name string
+			Type string
+			LinkTarget string
+			ModTime time.Time
+			HasOwner bool
+			Uid int
+			Gid int
 		}
-	} else {
-		if filepath.Base(hostRelPath) == "." {
-			dropletPath, _ = syscall.FullPath(".")
-		} else {
-			dropletPath, _ = syscall.FullPath(filepath.Base(hostRelPath))
+		type folderInfo struct {
+			Perm os.FileMode
+			ModTime time.Time
+			HasOwner bool
+			Uid int
+			Gid int
+			Entries []folderEntry
 		}
-	}
-
-	// Handle files or folders.
-	var handleFileFolder func(hostRelPath, dropletPath string)
-	handleFileFolder = func(hostRelPath, dropletPath string) {
-		resp, err := structuredhttp.GET("http://127.0.0.1:8190/v1/GetHost").Query("path", hostRelPath).Run()
+		b, err := resp.Bytes()
+		if err != nil {
+			fatalError(err)
+		}
+		var info folderInfo
+		err = gob.NewDecoder(bytes.NewReader(b)).Decode(&info)
 		if err != nil {
-			panic(err)
+			fatalError(err)
 		}
-		if resp.RaiseForStatus() != nil {
-			t, _ := resp.Text()
-			println(t)
+
+		// Ensure the folder doesn't exist.
+		if _, err := os.Stat(dropletPath); !os.IsNotExist(err) {
+			println("folder already exists")
 			os.Exit(1)
 		}
-		if resp.RawResponse.Header.Get("Is-Folder") == "true" {
-			// Get the folder information.
-			type folderInfo struct {
-				Perm os.FileMode
-				Contents []string
+
+		// Handle making the directory if it doesn't exist.
+		_ = os.MkdirAll(dropletPath, info.Perm)
+
+		// Handle each item in the folder which passes the filters. Symlinks are recreated rather than followed.
+		for _, c := range info.Entries {
+			cRel := filepath.Join(rel, c.Name)
+			if !filter.Included(cRel, c.Type == "dir") {
+				continue
 			}
-			b, err := resp.Bytes()
-			if err != nil {
-				panic(err)
+			if c.Type == "symlink" {
+				linkPath := filepath.Join(dropletPath, c.Name)
+				err := os.Symlink(c.LinkTarget, linkPath)
+				if err != nil {
+					println(err.Error())
+					os.Exit(1)
+				}
+				applySymlinkMetadata(linkPath, c.ModTime, c.HasOwner, c.Uid, c.Gid)
+				continue
 			}
-			var info folderInfo
-			err = gob.NewDecoder(bytes.NewReader(b)).Decode(&info)
+			handleFileFolder(filepath.Join(hostRelPath, c.Name), filepath.Join(dropletPath, c.Name), cRel)
+		}
+
+		// Apply the folder metadata now that the contents are written.
+		_ = os.Chmod(dropletPath, info.Perm)
+		applyMetadata(dropletPath, info.ModTime, info.HasOwner, info.Uid, info.Gid)
+	} else {
+		// Get the file perms.
+		x, err := strconv.ParseUint(resp.RawResponse.Header.Get("Perm"), 10, 64)
+		if err != nil {
+			fatalError(err)
+		}
+		perms := os.FileMode(x)
+
+		// Get the modification time and owner.
+		var modTime time.Time
+		if n, err := strconv.ParseInt(resp.RawResponse.Header.Get("Mod-Time"), 10, 64); err == nil {
+			modTime = time.Unix(0, n)
+		}
+		uid, uidErr := strconv.Atoi(resp.RawResponse.Header.Get("Uid"))
+		gid, gidErr := strconv.Atoi(resp.RawResponse.Header.Get("Gid"))
+
+		// Handle writing the file to stdout.
+		defer resp.RawResponse.Body.Close()
+		if dropletPath == "-" {
+			prog.StartFile(hostRelPath, resp.RawResponse.ContentLength)
+			_, err = io.Copy(os.Stdout, prog.Reader(resp.RawResponse.Body))
 			if err != nil {
-				panic(err)
+				fatalError(err)
 			}
+			prog.EndFile()
+			return
+		}
 
-			// Ensure the folder doesn't exist.
-			if _, err := os.Stat(dropletPath); !os.IsNotExist(err) {
-				println("folder already exists")
+		// Ensure the file doesn't exist.
+		if s, err := os.Stat(dropletPath); !os.IsNotExist(err) {
+			if s.IsDir() {
+				dropletPath = filepath.Join(dropletPath, filepath.Base(hostRelPath))
+			} else {
+				println("file already exists")
 				os.Exit(1)
 			}
+		}
 
-			// Handle making the directory if it doesn't exist.
-			_ = os.MkdirAll(dropletPath, info.Perm)
+		// Write the file.
+		w, err := os.Create(dropletPath)
+		if err != nil {
+			fatalError(err)
+		}
+		prog.StartFile(hostRelPath, resp.RawResponse.ContentLength)
+		_, err = io.Copy(w, prog.Reader(resp.RawResponse.Body))
+		_ = w.Close()
+		if err != nil {
+			fatalError(err)
+		}
+		prog.EndFile()
+		_ = os.Chmod(dropletPath, perms)
+		applyMetadata(dropletPath, modTime, uidErr == nil && gidErr == nil, uid, gid)
+	}
+}
 
-			// Handle each file in the folder.
-			for _, c := range info.Contents {
-				handleFileFolder(filepath.Join(hostRelPath, c), filepath.Join(dropletPath, c))
-			}
-		} else {
-			// Get the file perms.
-			x, err := strconv.ParseUint(resp.RawResponse.Header.Get("Perm"), 10, 64)
-			if err != nil {
-				panic(err)
-			}
-			perms := os.FileMode(x)
-
-			// Ensure the file doesn't exist.
-			if s, err := os.Stat(dropletPath); !os.IsNotExist(err) {
-				if s.IsDir() {
-					dropletPath = filepath.Join(dropletPath, filepath.Base(hostRelPath))
-				} else {
-					println("file already exists")
-					os.Exit(1)
-				}
-			}
+// The main function.
+func main() {
+	// Parse the flags and check the arg count.
+	flag.Var(&filter.Includes, "include", "Only copies files which match this pattern. Can be specified multiple times.")
+	flag.Var(&filter.Excludes, "exclude", "Skips files and folders which match this pattern. Can be specified multiple times.")
+	flag.Usage = usage
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+	}
 
-			// Write the file.
-			w, err := os.Create(dropletPath)
-			if err != nil {
-				panic(err)
-			}
-			defer w.Close()
-			defer resp.RawResponse.Body.Close()
-			_, err = io.Copy(w, resp.RawResponse.Body)
-			if err != nil {
-				panic(err)
+	// Get the host sources and the droplet path. If there is more than one argument, the last one is the droplet path.
+	sources := args
+	dropletPath := ""
+	if len(args) > 1 {
+		sources = args[:len(args)-1]
+		dropletPath = args[len(args)-1]
+	}
+
+	// Stdin/stdout can only be used on the droplet side.
+	for _, v := range sources {
+		if v == "-" {
+			println("- can only be used for the droplet side of the copy")
+			os.Exit(1)
+		}
+	}
+
+	// Expand the sources on the host and filter them.
+	var matches []globMatch
+	var total int64
+	for _, v := range sources {
+		for _, m := range globHost(v) {
+			if !filter.Included(filepath.Base(m.Path), m.IsDir) {
+				continue
 			}
-			_ = os.Chmod(dropletPath, perms)
+			matches = append(matches, m)
+			total += m.Size
+		}
+	}
+	if len(matches) == 0 {
+		println("no files to copy")
+		os.Exit(1)
+	}
+
+	// Handle copying a file to stdout. The progress is written to stderr so it doesn't end up in the output.
+	if dropletPath == "-" {
+		if len(matches) != 1 || matches[0].IsDir {
+			println("only a single file can be copied to stdout")
+			os.Exit(1)
+		}
+		prog = copytool.NewProgress(*quiet, *progressFormat, total, os.Stderr)
+		handleFileFolder(matches[0].Path, "-", "")
+		return
+	}
+	prog = copytool.NewProgress(*quiet, *progressFormat, total, os.Stdout)
+
+	// Handle a single source being copied to a path.
+	if len(matches) == 1 {
+		hostRelPath := matches[0].Path
+		if dropletPath == "" {
+			dropletPath = filepath.Base(hostRelPath)
+		}
+		dropletPath, err := filepath.Abs(dropletPath)
+		if err != nil {
+			fatalError(err)
 		}
+		handleFileFolder(hostRelPath, dropletPath, "")
+		return
+	}
+
+	// Handle multiple sources being copied into a folder.
+	if dropletPath == "" {
+		dropletPath = "."
+	}
+	dropletPath, err := filepath.Abs(dropletPath)
+	if err != nil {
+		fatalError(err)
+	}
+	err = os.MkdirAll(dropletPath, 0755)
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+	for _, m := range matches {
+		handleFileFolder(m.Path, filepath.Join(dropletPath, filepath.Base(m.Path)), "")
 	}
-	handleFileFolder(hostRelPath, dropletPath)
 }
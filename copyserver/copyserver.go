@@ -24,20 +24,118 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
+	"time"
+)
+
+// Defines the types of item which can be copied.
+const (
+	typeFile    = "file"
+	typeDir     = "dir"
+	typeSymlink = "symlink"
 )
 
 // Defines information about the transfer.
 type transferInformation struct {
 	totalBytes uint
 	writtenBytes uint
+	stream bool
 	writer io.WriteCloser
+	path string
+	data *transferInit
 }
 
-// The data used to initialise a transfer.
+// The data used to initialise a transfer. If Stream is true, the total bytes are unknown and the transfer ends on a fragment with the Transfer-End header.
+// Type defaults to a file. Folders and symlinks do not have any fragments. The owner is only applied if HasOwner is true.
 type transferInit struct {
 	LocalPath string
 	TotalBytes uint
 	Perm os.FileMode
+	Stream bool
+	Type string
+	LinkTarget string
+	ModTime time.Time
+	HasOwner bool
+	Uid int
+	Gid int
+}
+
+// Defines an item within a folder. The metadata is of the item itself, so symlinks are not followed.
+type folderEntry struct {
+	Name string
+	Type string
+	LinkTarget string
+	ModTime time.Time
+	HasOwner bool
+	Uid int
+	Gid int
+}
+
+// Defines the information about a folder.
+type folderInfo struct {
+	Perm os.FileMode
+	ModTime time.Time
+	HasOwner bool
+	Uid int
+	Gid int
+	Entries []folderEntry
+}
+
+// Used to apply the metadata of a transfer once the item is written. Ownership is best effort since it requires privileges on the host.
+func applyMetadata(path string, data *transferInit) error {
+	if data.Type != typeSymlink {
+		if err := os.Chmod(path, data.Perm.Perm()); err != nil {
+			return err
+		}
+		if !data.ModTime.IsZero() {
+			if err := os.Chtimes(path, data.ModTime, data.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+	if data.HasOwner {
+		_ = os.Lchown(path, data.Uid, data.Gid)
+	}
+	return nil
+}
+
+// Used to get the type of a file.
+func fileType(s os.FileInfo) string {
+	if s.IsDir() {
+		return typeDir
+	}
+	if s.Mode()&os.ModeSymlink != 0 {
+		return typeSymlink
+	}
+	return typeFile
+}
+
+// Logf is used to log the requests which are handled. By default, nothing is logged.
+var Logf = func(format string, v ...interface{}) {}
+
+// Used to record the status code of a response for logging.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Used to log each request and the status it got.
+func logRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		q := r.URL.Query()
+		if arg := q.Get("path") + q.Get("pattern"); arg != "" {
+			Logf("copyserver: %s %s (%s): %d", r.Method, r.URL.Path, arg, rec.status)
+		} else {
+			Logf("copyserver: %s %s: %d", r.Method, r.URL.Path, rec.status)
+		}
+	})
 }
 
 // Copyserver is used to handle copying between the droplet and host.
@@ -45,8 +143,9 @@ func Copyserver(ln net.Listener) error {
 	// Defines the router.
 	router := httprouter.New()
 
-	// Defines a map of transfer ID > transfer information.
+	// Defines a map of transfer ID > transfer information. Several sessions can copy at once, so this is locked.
 	transferMap := map[string]*transferInformation{}
+	var transferLock sync.Mutex
 
 	// Create the transfer session.
 	router.POST("/v1/StartTransferSession", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -68,8 +167,10 @@ func Copyserver(ln net.Listener) error {
 			return
 		}
 
-		// Check if the path exists.
-		if _, err := os.Stat(fullPath); !os.IsNotExist(err) {
+		Logf("copyserver: receiving %s %s (%d bytes)", data.Type, fullPath, data.TotalBytes)
+
+		// Check if the path exists. Folders are allowed to exist since their metadata is sent after their contents.
+		if s, err := os.Lstat(fullPath); !os.IsNotExist(err) && !(data.Type == typeDir && err == nil && s.IsDir()) {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte("file already exists"))
 			return
@@ -77,13 +178,36 @@ func Copyserver(ln net.Listener) error {
 
 		// Make the directory if it doesn't exist.
 		dir, _ := filepath.Split(fullPath)
-		err = os.MkdirAll(dir, data.Perm)
+		err = os.MkdirAll(dir, 0755)
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
 
+		// Get the transfer ID.
+		id := uuid.New().String()
+
+		// Handle folders and symlinks.
+		if data.Type == typeDir || data.Type == typeSymlink {
+			if data.Type == typeDir {
+				err = os.MkdirAll(fullPath, 0755)
+			} else {
+				err = os.Symlink(data.LinkTarget, fullPath)
+			}
+			if err == nil {
+				err = applyMetadata(fullPath, &data)
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(id))
+			return
+		}
+
 		// Create the file.
 		f, err := os.Create(fullPath)
 		if err != nil {
@@ -92,17 +216,25 @@ func Copyserver(ln net.Listener) error {
 			return
 		}
 
-		// Get the transfer ID.
-		id := uuid.New().String()
-
 		// Create the transfer information.
-		if data.TotalBytes == 0 {
+		if data.TotalBytes == 0 && !data.Stream {
 			_ = f.Close()
+			err = applyMetadata(fullPath, &data)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
 		} else {
+			transferLock.Lock()
 			transferMap[id] = &transferInformation{
 				totalBytes:   data.TotalBytes,
+				stream:       data.Stream,
 				writer:       f,
+				path:         fullPath,
+				data:         &data,
 			}
+			transferLock.Unlock()
 		}
 
 		// Write the transfer ID.
@@ -140,26 +272,41 @@ func Copyserver(ln net.Listener) error {
 			return
 		}
 
+		// Get the owner if possible.
+		uid, gid, hasOwner := fileOwner(s)
+
 		// Check if this is a folder.
 		if s.IsDir() {
-			type folderInfo struct {
-				Perm os.FileMode
-				Contents []string
-			}
 			c, err := ioutil.ReadDir(fullPath)
 			if err != nil {
 				w.WriteHeader(http.StatusBadRequest)
 				_, _ = w.Write([]byte(err.Error()))
 				return
 			}
-			a := make([]string, len(c))
+			a := make([]folderEntry, len(c))
 			for i, v := range c {
-				a[i] = v.Name()
+				// ReadDir uses Lstat, so this is the metadata of the entry rather than any symlink target.
+				entryUid, entryGid, entryHasOwner := fileOwner(v)
+				a[i] = folderEntry{
+					Name:     v.Name(),
+					Type:     fileType(v),
+					ModTime:  v.ModTime(),
+					HasOwner: entryHasOwner,
+					Uid:      entryUid,
+					Gid:      entryGid,
+				}
+				if a[i].Type == typeSymlink {
+					a[i].LinkTarget, _ = os.Readlink(filepath.Join(fullPath, v.Name()))
+				}
 			}
 			buf := &bytes.Buffer{}
 			info := &folderInfo{
 				Perm:     s.Mode().Perm(),
-				Contents: a,
+				ModTime:  s.ModTime(),
+				HasOwner: hasOwner,
+				Uid:      uid,
+				Gid:      gid,
+				Entries:  a,
 			}
 			encoder := gob.NewEncoder(buf)
 			err = encoder.Encode(info)
@@ -180,6 +327,12 @@ func Copyserver(ln net.Listener) error {
 			}
 			w.Header().Set("Is-Folder", "false")
 			w.Header().Set("Perm", strconv.FormatUint(uint64(s.Mode().Perm()), 10))
+			w.Header().Set("Content-Length", strconv.FormatInt(s.Size(), 10))
+			w.Header().Set("Mod-Time", strconv.FormatInt(s.ModTime().UnixNano(), 10))
+			if hasOwner {
+				w.Header().Set("Uid", strconv.Itoa(uid))
+				w.Header().Set("Gid", strconv.Itoa(gid))
+			}
 			w.WriteHeader(http.StatusOK)
 			defer reader.Close()
 			_, err = io.Copy(w, reader)
@@ -191,26 +344,79 @@ func Copyserver(ln net.Listener) error {
 		}
 	})
 
+	// List the host paths which match a glob pattern.
+	router.GET("/v1/GlobHost", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		// Get the query param for the pattern.
+		pattern := r.URL.Query().Get("pattern")
+		if pattern == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("no pattern given"))
+			return
+		}
+
+		// Get the matches.
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		if len(matches) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("no matches for " + pattern))
+			return
+		}
+
+		// Get the information about each match. The size of a folder is the total size of its contents.
+		type globMatch struct {
+			Path string
+			Size int64
+			IsDir bool
+		}
+		a := make([]globMatch, len(matches))
+		for i, v := range matches {
+			s, err := os.Stat(v)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(err.Error()))
+				return
+			}
+			a[i] = globMatch{Path: v, Size: s.Size(), IsDir: s.IsDir()}
+			if s.IsDir() {
+				a[i].Size = 0
+				_ = filepath.Walk(v, func(_ string, s os.FileInfo, err error) error {
+					if err == nil && !s.IsDir() {
+						a[i].Size += s.Size()
+					}
+					return nil
+				})
+			}
+		}
+		buf := &bytes.Buffer{}
+		err = gob.NewEncoder(buf).Encode(a)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.Copy(w, buf)
+	})
+
 	// Handle a transfer fragment.
 	router.POST("/v1/HandleFragment", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		// Get the transfer.
 		TransferID := r.Header.Get("Transfer-ID")
+		transferLock.Lock()
 		transfer, ok := transferMap[TransferID]
+		transferLock.Unlock()
 		if !ok {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte("transfer not active"))
 			return
 		}
 
-		// Handle adding the bytes.
-		length := r.ContentLength
-		if length == -1 {
-			w.WriteHeader(http.StatusBadRequest)
-			_, _ = w.Write([]byte("length unknown"))
-			return
-		}
-
-		// Read the bytes.
+		// Read the bytes. The body may be chunked, so the content length is not relied on.
 		b, err := ioutil.ReadAll(r.Body)
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -220,14 +426,14 @@ func Copyserver(ln net.Listener) error {
 		r.Body.Close()
 
 		// Check if length is greater than remaining.
-		if uint(len(b)) > transfer.totalBytes-transfer.writtenBytes {
+		if !transfer.stream && uint(len(b)) > transfer.totalBytes-transfer.writtenBytes {
 			w.WriteHeader(http.StatusBadRequest)
 			_, _ = w.Write([]byte("length greater than total required"))
 			return
 		}
 
 		// Add the length to the written bytes.
-		transfer.writtenBytes += uint(length)
+		transfer.writtenBytes += uint(len(b))
 
 		// Write the bytes.
 		_, err = transfer.writer.Write(b)
@@ -239,15 +445,18 @@ func Copyserver(ln net.Listener) error {
 
 		// Write 204.
 		w.WriteHeader(http.StatusNoContent)
-		if transfer.writtenBytes == transfer.totalBytes {
+		if (!transfer.stream && transfer.writtenBytes == transfer.totalBytes) || (transfer.stream && r.Header.Get("Transfer-End") == "true") {
 			_ = transfer.writer.Close()
+			_ = applyMetadata(transfer.path, transfer.data)
+			transferLock.Lock()
 			delete(transferMap, TransferID)
+			transferLock.Unlock()
 		}
 	})
 
 	// Create the server.
 	s := http.Server{
-		Handler: router,
+		Handler: logRequests(router),
 	}
 	return s.Serve(ln)
 }
@@ -0,0 +1,22 @@
+// +build windows
+
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package copyserver
+
+import "os"
+
+// Used to get the uid/gid of a file. Windows has no uid/gid so this always returns false.
+func fileOwner(os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
@@ -13,89 +13,164 @@
 package main
 
 import (
-	"fmt"
+	"errors"
 	"github.com/do-community/do-disposable/copyserver"
-	"github.com/buger/goterm"
 	"github.com/digitalocean/godo"
 	"github.com/google/uuid"
 	"github.com/shiena/ansicolor"
 	"golang.org/x/crypto/ssh"
 	"io"
-	"io/ioutil"
-	"log"
-	"net/http"
+	"net"
 	"os"
+	"os/exec"
 	"os/signal"
-	"regexp"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
-// Generic error for interrupt.
-type interruptType struct{}
-func (interruptType) Error() string { return "" }
-var interrupt = &interruptType{}
-
-// Check if the connection is IPv6.
-func checkIfIpv6() bool {
-	reqError := func() bool {
-		println("Unable to determine if the connection is IPv6. Going to attempt to default to connecting to the droplet via IPv4.")
-		return false
-	}
-	resp, err := http.Get("https://api64.ipify.org")
-	if err != nil || resp.StatusCode != 200 {
-		return reqError()
-	}
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return reqError()
-	}
-	matched, err := regexp.Match("[0-9]+\\.[0-9]+\\.[0-9]+\\.[0-9]+", b)
-	if err != nil {
-		return reqError()
-	}
-	return !matched
+// Defines the options which are used to create the disposable droplet.
+type dropletOptions struct {
+	region         string
+	size           string
+	image          godo.DropletCreateImage
+	watches        []*watchSpec
+	snapshotOnExit bool
+	volume         volumeOptions
+	vpc            string
+	tags           []string
+	monitoring     bool
+	firewall       bool
+	project        *godo.Project
+	family         int
+	private        bool
+	record         *recorder
+	execSSH        bool
+	user           string
+	sync           *syncConfig
+	env            map[string]string
+	noTools        bool
 }
 
-// This function is used to create the disposable droplet/kill it.
-func handleDisposableDroplet(region, size, distro string) {
-	// Determine if we are on a IPv6 connection.
-	ipv6 := checkIfIpv6()
-
+// This function is used to create the disposable droplet/kill it. Any error is returned after the droplet is destroyed.
+func handleDisposableDroplet(opts *dropletOptions) (failure error) {
 	// Defines the droplet ID.
 	ID := uuid.New().String()
 
+	// Find the existing volume before creating anything so that a typo fails early.
+	var volume *godo.Volume
+	var err error
+	if opts.volume.name != "" {
+		volume, err = findVolume(opts.volume.name, opts.region)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Create the droplet.
+	logger.Debugf("Creating the droplet %s in %s with the size %s and image %+v.", ID, opts.region, opts.size, opts.image)
 	print("Creating droplet... ")
 	d, _, err := client.Droplets.Create(context(), &godo.DropletCreateRequest{
 		Name:              ID,
-		Region:            region,
-		Size:              size,
-		Image:             godo.DropletCreateImage{Slug: distro},
+		Region:            opts.region,
+		Size:              opts.size,
+		Image:             opts.image,
 		SSHKeys:           []godo.DropletCreateSSHKey{{ID: config.KeyID}},
 		IPv6:              true,
-		Tags:              []string{"do-disposable"},
+		Monitoring:        opts.monitoring,
+		Tags:              append([]string{"do-disposable"}, opts.tags...),
+		VPCUUID:           opts.vpc,
 	})
 	if err != nil {
-		panic(err)
+		println("failed!")
+		return err
 	}
 	println("done!")
+	logger.Debugf("Created the droplet with the ID %d.", d.ID)
+
+	// Assign the droplet to the project.
+	assignToProject(opts.project, d)
+
+	// Create the firewall.
+	var fw *godo.Firewall
+	if opts.firewall {
+		fw, err = createFirewall("do-disposable-"+ID, d.ID)
+		if err != nil {
+			// Nothing has been set up to destroy the droplet yet, so do that before returning.
+			_, _ = client.Droplets.Delete(context(), d.ID)
+			return err
+		}
+	}
 
-	// From here, we should try and ensure that any panic/exit destroys this droplet.
+	// From here, we should try and ensure that any error/panic/exit destroys this droplet.
 	// The destruction should allow for bad internet connections and should be patient.
+	dropletID, dropletName := d.ID, d.Name
+	volumeAttached := false
+
+	// Set when do-disposable is terminated during the session. Nobody is there to answer prompts then.
+	terminated := false
+
+	// The state which is filled in by the setup goroutine. The main loop reads it with the lock held, and the teardown reads it once the setup goroutine has exited.
+	var stateLock sync.Mutex
+	sessionStarted := false
+	var sshClient *ssh.Client
+	var term *localTerminal
+	var secretsPath string
+	var syncEnvPath string
+	closeControl := func() {}
+
+	// Closed to stop the setup goroutine, and closed by the setup goroutine when it has exited.
+	setupCancel := make(chan struct{})
+	setupDone := make(chan struct{})
+	setupStarted := false
 	defer func() {
 		// Handle describing what happened to the user.
 		r := recover()
-		if r == nil {
+
+		// Stop the setup and wait for it so that nothing it creates is left behind by the teardown.
+		close(setupCancel)
+		if setupStarted {
+			<-setupDone
+		}
+
+		// Put the terminal back to normal so that the output below and any prompts work.
+		term.restore()
+		_ = opts.record.Close()
+		closeControl()
+		removeSSHConfig(dropletName)
+
+		// Remove any secrets from the droplet.
+		scrubSecrets(sshClient, secretsPath)
+		scrubSecrets(sshClient, syncEnvPath)
+
+		// Detach the volume before anything else happens to the droplet.
+		if volumeAttached {
+			detachVolume(sshClient, volume, opts.volume.mountPath, dropletID)
+		}
+
+		if r == nil && (failure == nil || failure == errInterrupted) {
+			// Handle snapshotting the droplet if this is wanted.
+			if sessionStarted && (!terminated || opts.snapshotOnExit) {
+				if err := handleSnapshotOnExit(dropletID, !opts.snapshotOnExit); err != nil {
+					failure = err
+					return
+				}
+			}
 			println("The application was exited. Destroying the droplet before quitting. Note that closing the process before this is done will mean you'll have to manually delete the droplet.")
+		} else if r == nil {
+			println("An error occurred. Destroying the droplet before quitting. Note that closing the process before this is done will mean you'll have to manually delete the droplet.")
 		} else {
-			log.Print(r)
+			logger.Errorf("%v", r)
+			logger.Debugf("%s", debug.Stack())
 			println("The application crashed. Destroying the droplet before quitting. Note that closing the process before this is done will mean you'll have to manually delete the droplet.")
 		}
 
 		// Try destroying the droplet.
+		logger.Debugf("Destroying the droplet %d.", dropletID)
 		for {
-			resp, err := client.Droplets.Delete(context(), d.ID)
+			resp, err := client.Droplets.Delete(context(), dropletID)
 			if resp != nil {
 				if resp.StatusCode == 404 {
 					println("Droplet no longer exists.")
@@ -106,11 +181,26 @@ func handleDisposableDroplet(region, size, distro string) {
 			if err == nil {
 				break
 			}
-			log.Println("Failed to delete droplet. Will try again: ", err)
+			logger.Warnf("Failed to delete droplet. Will try again: %v", err)
 		}
 
 		// Log that the droplet was deleted.
 		println("Droplet deleted.")
+
+		// Delete the firewall.
+		if fw != nil {
+			deleteFirewall(fw)
+		}
+
+		// Delete the volume if it was created for this droplet and this is wanted.
+		if volume != nil && opts.volume.newSize != 0 && opts.volume.deleteOnExit {
+			_, err := client.Storage.DeleteVolume(context(), volume.ID)
+			if err != nil {
+				logger.Errorf("Failed to delete the volume %s. You will need to manually delete it: %v", volume.Name, err)
+			} else {
+				println("Volume deleted.")
+			}
+		}
 		if r != nil {
 			os.Exit(1)
 		}
@@ -119,31 +209,50 @@ func handleDisposableDroplet(region, size, distro string) {
 	// The channel which is used for errors (and nils to represent moving along).
 	errorChan := make(chan error)
 
-	// Defines if the droplet actions should still be running.
-	// During a CTRL+C this would be set to false.
-	dropletActionsActive := true
+	// Used to send to the error channel. This gives up once the teardown has started since nothing reads the channel after that.
+	report := func(err error) {
+		select {
+		case errorChan <- err:
+		case <-setupCancel:
+		}
+	}
+
+	// Used to check if the setup should stop.
+	cancelled := func() bool {
+		select {
+		case <-setupCancel:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// Closed when the shell session ends.
+	sessionDone := make(chan struct{})
 
-	// Handle CTRL+C.
-	c := make(chan os.Signal)
+	// Handle CTRL+C. Until the session starts, this stops everything. After that, signals are forwarded to the session.
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-c
-		dropletActionsActive = false
-		errorChan <- interrupt
+		for sig := range c {
+			report(&signalError{sig: sig})
+		}
 	}()
 
 	// Handle the waiting for the droplet.
 	print("Waiting for droplet to be active... ")
+	active := make(chan *godo.Droplet, 1)
 	go func() {
-		for dropletActionsActive {
+		for !cancelled() {
 			time.Sleep(time.Second)
-			d, _, err = client.Droplets.Get(context(), d.ID)
+			current, _, err := client.Droplets.Get(context(), dropletID)
 			if err != nil {
-				errorChan <- err
+				report(err)
 				return
 			}
-			if d.Status == "active" {
-				errorChan <- nil
+			if current.Status == "active" {
+				active <- current
+				report(nil)
 				println("done!")
 				return
 			}
@@ -152,74 +261,204 @@ func handleDisposableDroplet(region, size, distro string) {
 
 	// Handle errors/pass through for the initial creation.
 	err = <-errorChan
-	if err == interrupt {
-		return
+	if _, ok := err.(*signalError); ok {
+		return errInterrupted
 	} else if err != nil {
-		panic(err)
+		return err
+	}
+	d = <-active
+
+	// Create/attach the volume now that the droplet is active.
+	if opts.volume.newSize != 0 {
+		volume, err = createVolume("do-disposable-"+ID, opts.region, opts.volume.newSize)
+		if err != nil {
+			return err
+		}
+		assignToProject(opts.project, volume)
+	}
+	if volume != nil {
+		if opts.volume.mountPath == "" {
+			opts.volume.mountPath = "/mnt/" + volume.Name
+		}
+		err = attachVolume(volume, dropletID)
+		if err != nil {
+			return err
+		}
+		volumeAttached = true
 	}
 
 	// Keep trying to connect via SSH until it works.
-	var ip string
-	network := "tcp"
-	if ipv6 {
-		ip, _ = d.PublicIPv6()
-		network = "tcp6"
-	} else {
-		ip, _ = d.PublicIPv4()
+	addrs := dropletAddresses(d, opts.family)
+	if opts.private {
+		privateIP, err := d.PrivateIPv4()
+		if err != nil || privateIP == "" {
+			return errors.New("the droplet has no private network address, so -private can't be used")
+		}
+		addrs = []string{net.JoinHostPort(privateIP, "22")}
+	} else if opts.family != 6 && onSamePrivateNetwork(d) {
+		// Prefer the private network since it doesn't use public bandwidth, but keep the public addresses to fall back on.
+		privateIP, _ := d.PrivateIPv4()
+		println("Running on a droplet in the same private network, so connecting over it.")
+		addrs = append([]string{net.JoinHostPort(privateIP, "22")}, addrs...)
+	}
+	if len(addrs) == 0 {
+		return networkError(errors.New("the droplet has no address which this machine has a route to"))
 	}
+	logger.Debugf("Connecting to the droplet at %s.", strings.Join(addrs, ", "))
 	print("Waiting for the droplet to accept SSH connections... ")
 	signer, err := ssh.NewSignerFromKey(config.PrivateKey)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	var client *ssh.Client
+	var session *ssh.Session
 	var stdin io.WriteCloser
+	setupStarted = true
 	go func() {
+		defer close(setupDone)
+
 		// Wait for SSH to be ready.
+		var client *ssh.Client
+		var hostKey ssh.PublicKey
+		var err error
+		lastDialErr := ""
 		for {
-			client, err = ssh.Dial(network, ip+":22", &ssh.ClientConfig{
+			if cancelled() {
+				return
+			}
+			client, err = dialDroplet(addrs, &ssh.ClientConfig{
 				User:              "root",
 				Auth:              []ssh.AuthMethod{ssh.PublicKeys(signer)},
 
 				// Sadly it is impossible to make the initial handshake secure here since this is the first time we see the host.
-				// After the initial handshake, the rest of the data will be encrypted.
-				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+				// After the initial handshake, the rest of the data will be encrypted. The key is kept so that it can be pinned for OpenSSH.
+				HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+					hostKey = key
+					return nil
+				},
 			})
 			if err != nil {
+				if err.Error() != lastDialErr {
+					lastDialErr = err.Error()
+					logger.Debugf("Unable to connect to the droplet yet: %v", err)
+				}
 				continue
 			}
 			break
 		}
+		logger.Debugf("Connected to the droplet at %s.", client.RemoteAddr())
+		stateLock.Lock()
+		sshClient = client
+		stateLock.Unlock()
 		println("done!")
 
-		// Handle copyback/copyfrom init.
-		session, err := client.NewSession()
-		if err != nil {
-			errorChan <- err
+		// Restrict SSH access to our IP.
+		if fw != nil {
+			err = restrictFirewall(fw, client, dropletID)
+			if err != nil {
+				report(err)
+				return
+			}
+		}
+
+		// Handle copyback/copyfrom init. If the binaries aren't embedded in this build, fall back to downloading them on the droplet.
+		if !opts.noTools && !cancelled() {
+			err = installDropletTools(client)
+			if err == errToolsNotEmbedded {
+				err = downloadDropletTools(client)
+			}
+			if err == errNoPinnedDigests {
+				// The session is still useful without them.
+				logger.Warnf("This build of do-disposable has no droplet tools for this droplet, so copyfrom and copyback won't be available on it (build with build.sh).")
+				err = nil
+			}
+			if err != nil {
+				report(err)
+				return
+			}
+		}
+
+		// Mount the volume.
+		if volume != nil && !cancelled() {
+			err = mountVolume(client, volume, opts.volume.mountPath)
+			if err != nil {
+				report(err)
+				return
+			}
+			println("The volume " + volume.Name + " is mounted at " + opts.volume.mountPath + ".")
+		}
+
+		// Create the user and connect as it for everything else. The root connection is kept for the teardown.
+		user := "root"
+		if opts.user != "" && !cancelled() {
+			print("Creating the user " + opts.user + "... ")
+			err = createDropletUser(client, opts.user)
+			if err != nil {
+				report(err)
+				return
+			}
+			if volume != nil {
+				err = chownVolume(client, opts.volume.mountPath, opts.user)
+				if err != nil {
+					report(err)
+					return
+				}
+			}
+			client, err = dialDroplet(addrs, &ssh.ClientConfig{
+				User:            opts.user,
+				Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+				HostKeyCallback: ssh.FixedHostKey(hostKey),
+			})
+			if err != nil {
+				report(err)
+				return
+			}
+			user = opts.user
+			println("done!")
+		}
+
+		// Push the dotfiles and environment variables.
+		if cancelled() {
 			return
 		}
-		err = session.Run("wget -O - -o /dev/null https://community-tools.sfo2.digitaloceanspaces.com/droplet_init.sh | bash")
+		envPath, err := syncToDroplet(client, opts.sync)
+		stateLock.Lock()
+		syncEnvPath = envPath
+		stateLock.Unlock()
 		if err != nil {
-			errorChan <- err
+			report(err)
 			return
 		}
 
-		// Create a new session.
-		session, err = client.NewSession()
-		if err != nil {
-			errorChan <- err
-			return
+		// Used to start the control socket so that do-disposable shell can open more sessions. This is done once the secrets file is written.
+		startControl := func() {
+			if closer, err := serveControl(client, dropletName, opts.env); err == nil {
+				stateLock.Lock()
+				closeControl = closer
+				stateLock.Unlock()
+			} else {
+				logger.Warnf("Unable to start the control socket for do-disposable shell: %v", err)
+			}
 		}
 
-		// Get the IO pipes.
-		session.Stdout = ansicolor.NewAnsiColorWriter(os.Stdout)
-		session.Stderr = ansicolor.NewAnsiColorWriter(os.Stderr)
-		stdin, _ = session.StdinPipe()
+		// Used to write the secrets file.
+		writeSecretsFile := func(env map[string]string) error {
+			path, err := writeSecrets(client, env)
+			stateLock.Lock()
+			secretsPath = path
+			stateLock.Unlock()
+			return err
+		}
 
-		// Set the terminal modes which we want.
-		modes := ssh.TerminalModes{
-			ssh.ECHO:  0,
-			ssh.IGNCR: 1,
+		// Start pushing any watched host directories. A watch failing only stops that watch since the session may have work on it.
+		if cancelled() {
+			return
+		}
+		for _, w := range opts.watches {
+			go func(w *watchSpec) {
+				if err := watchAndPush(client, w); err != nil {
+					logger.Warnf("Stopped watching %s: %v", w.LocalDir, err)
+				}
+			}(w)
 		}
 
 		// Create a fasthttp server for the SSH server to be able to use.
@@ -227,74 +466,167 @@ func handleDisposableDroplet(region, size, distro string) {
 			// Create the listener on the SSH side.
 			listener, err := client.Listen("tcp", "127.0.0.1:8190")
 			if err != nil {
-				errorChan <- err
+				report(err)
 				return
 			}
 
 			// Create the server.
 			err = copyserver.Copyserver(listener)
 			if err != nil {
-				errorChan <- err
+				report(err)
 			}
 		}()
 
-		// Request pseudo terminal.
-		// TODO: The terminal doesn't handle some formatting right.
-		err = session.RequestPty("vt100", goterm.Height(), goterm.Width(), modes)
+		// Write the OpenSSH config so that other tools can connect to the droplet.
+		if cancelled() {
+			return
+		}
+		sshConfig, err := writeSSHConfig(dropletName, user, client.RemoteAddr(), hostKey)
 		if err != nil {
-			errorChan <- err
+			report(err)
 			return
 		}
 
-		// Loop handling the width/height.
-		go func() {
-			currentHeight := goterm.Height()
-			currentWidth := goterm.Width()
-			for dropletActionsActive {
-				// Get the width/height.
-				w := goterm.Width()
-				h := goterm.Height()
-
-				// Check if it's different.
-				if w != currentWidth || h != currentHeight {
-					// Set the new width/height.
-					currentWidth = w
-					currentHeight = h
-					err := session.WindowChange(h, w)
-					if err != nil {
-						errorChan <- err
-						return
-					}
+		// If the system ssh binary is wanted, use it for the session instead. Env requests can't be used, so the variables go in the secrets file.
+		if opts.execSSH {
+			if len(opts.env) != 0 {
+				err = writeSecretsFile(opts.env)
+				if err != nil {
+					report(err)
+					return
 				}
+			}
+			if cancelled() {
+				return
+			}
+			startControl()
+			stateLock.Lock()
+			sessionStarted = true
+			stateLock.Unlock()
+			go func() {
+				err := execSSH(sshConfig, dropletName, setupCancel)
+				if _, ok := err.(*exec.ExitError); ok {
+					// Like the built-in client, we don't care about old command errors.
+					err = nil
+				}
+				report(err)
+			}()
+			return
+		}
+
+		// Create a new session.
+		s, err := client.NewSession()
+		if err != nil {
+			report(err)
+			return
+		}
 
-				// Sleep for 100ms.
-				time.Sleep(time.Millisecond * 100)
+		// Get the IO pipes.
+		s.Stdout = ansicolor.NewAnsiColorWriter(os.Stdout)
+		s.Stderr = ansicolor.NewAnsiColorWriter(os.Stderr)
+		if opts.record != nil {
+			s.Stdout = io.MultiWriter(s.Stdout, opts.record)
+			s.Stderr = io.MultiWriter(s.Stderr, opts.record)
+		}
+		in, _ := s.StdinPipe()
+		stateLock.Lock()
+		session, stdin = s, in
+		stateLock.Unlock()
+
+		// Put the local terminal in raw mode so that the droplet handles echoing and line editing.
+		if cancelled() {
+			return
+		}
+		t, err := makeRaw()
+		stateLock.Lock()
+		term = t
+		stateLock.Unlock()
+		if err != nil {
+			report(err)
+			return
+		}
+
+		// Request a pseudo terminal if stdin is a terminal. Otherwise, the input is piped to the shell as it is.
+		w, h := terminalSize()
+		err = opts.record.begin(dropletName, w, h)
+		if err != nil {
+			report(err)
+			return
+		}
+		if t != nil {
+			err = s.RequestPty(terminalType(), h, w, ssh.TerminalModes{
+				ssh.TTY_OP_ISPEED: 14400,
+				ssh.TTY_OP_OSPEED: 14400,
+			})
+			if err != nil {
+				report(err)
+				return
 			}
-		}()
+
+			// Pass through any changes to the window size.
+			go watchWindowSize(sessionDone, func(w, h int) {
+				_ = s.WindowChange(h, w)
+				opts.record.resize(w, h)
+			})
+		}
+
+		// Pass through the environment variables. Any which the SSH server refuses are exported from the secrets file instead.
+		if refused := setSessionEnv(s, opts.env); len(refused) != 0 {
+			err = writeSecretsFile(refused)
+			if err != nil {
+				report(err)
+				return
+			}
+		}
+		if cancelled() {
+			return
+		}
+		startControl()
 
 		// Start SSH shell.
-		err = session.Shell()
+		err = s.Shell()
 		if err != nil {
-			errorChan <- err
+			report(err)
 			return
 		}
+		if cancelled() {
+			return
+		}
+		stateLock.Lock()
+		sessionStarted = true
+		stateLock.Unlock()
+
+		// Forward the other signals which the terminal would usually send now that there is a session to handle them.
+		signal.Notify(c, sessionSignals...)
 
-		// Handle input.
+		// Handle input. This stops when the session ends so that stdin can be used for prompts afterwards.
 		go func() {
-			ob := make([]byte, 1)
-			for dropletActionsActive {
-				_, err := os.Stdin.Read(ob)
-				if err != nil {
-					errorChan <- err
-					return
-				}
-				if ob[0] == '\r' {
-					// Windows.
-					continue
-				}
-				_, err = stdin.Write(ob)
-				if err != nil {
-					errorChan <- err
+			var escapes escapeScanner
+			for {
+				select {
+				case b, ok := <-stdinReader():
+					if !ok {
+						// Let the shell see the end of the input.
+						_ = in.Close()
+						return
+					}
+
+					// Handle any escape sequences. These are only used when interactive, like OpenSSH.
+					if t != nil {
+						var end bool
+						b, end = escapes.scan(b)
+						if end {
+							print("\r\nEnding the session.\r\n")
+							report(nil)
+							return
+						}
+					}
+					_, err := in.Write(b)
+					if err != nil {
+						report(err)
+						return
+					}
+				case <-sessionDone:
 					return
 				}
 			}
@@ -303,35 +635,52 @@ func handleDisposableDroplet(region, size, distro string) {
 		// Handle waiting for disconnect.
 		go func() {
 			// Wait for the session.
-			err := session.Wait()
+			err := s.Wait()
+			t.restore()
+			close(sessionDone)
 
 			// If this is a exit error, return nil since we don't care about old command errors.
 			if _, ok := err.(*ssh.ExitError); ok {
-				errorChan <- nil
+				report(nil)
+				return
 			}
 
 			// If not, return the error.
-			errorChan <- err
+			report(err)
 		}()
 	}()
 
 	// Handle any new errors/the client closing.
 	for {
 		err := <-errorChan
-		if err == interrupt {
-			if client == nil {
-				dropletActionsActive = false
-				return
-			} else {
-				_, err = fmt.Fprint(stdin, "\x03")
-				if err != nil {
-					panic(err)
+		if s, ok := err.(*signalError); ok {
+			stateLock.Lock()
+			started, currentSession, currentStdin, pty := sessionStarted, session, stdin, term != nil
+			stateLock.Unlock()
+			if !started {
+				return errInterrupted
+			}
+			if s.sig == syscall.SIGTERM {
+				// Like OpenSSH, being terminated ends the session even if the shell ignores the signal, so that supervisors can stop us cleanly.
+				if !opts.execSSH {
+					_ = forwardSignal(currentSession, currentStdin, pty, s.sig)
 				}
+				print("\r\nTerminated. Ending the session.\r\n")
+				terminated = true
+				return errInterrupted
+			}
+			if opts.execSSH {
+				// ssh handles the terminal itself.
+				continue
+			}
+			err = forwardSignal(currentSession, currentStdin, pty, s.sig)
+			if err != nil {
+				return err
 			}
 		} else if err != nil {
-			panic(err)
+			return err
 		} else {
-			break
+			return nil
 		}
 	}
 }
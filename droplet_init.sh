@@ -1,18 +1,106 @@
 #!/bin/sh
+# Copyright 2020 DigitalOcean
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#		http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
 # The first script which is ran to initialise the droplet.
+# This is only used when the copyback/copyfrom binaries for the droplet are not embedded in do-disposable.
+# The SHA256SUMS environment variable must contain the digests pinned into do-disposable (in the sha256sum format).
+# The binaries are verified against these before they are made executable.
 
 set -e
 
 unamestr=$(uname)
 if [ "$unamestr" = 'Linux' ]; then
-   sudo wget -O /usr/local/bin/copyback https://community-tools.sfo2.digitaloceanspaces.com/copyback_linux
-   sudo wget -O /usr/local/bin/copyfrom https://community-tools.sfo2.digitaloceanspaces.com/copyfrom_linux
-elif [ "$unamestr" = 'FreeBSD' ]; then
-   sudo wget -O /usr/local/bin/copyback https://community-tools.sfo2.digitaloceanspaces.com/copyback_freebsd
-   sudo wget -O /usr/local/bin/copyfrom https://community-tools.sfo2.digitaloceanspaces.com/copyfrom_freebsd
+   platform="linux"
 else
   echo "Unknown platform." 1>&2
   exit 1
 fi
 
-chmod 777 /usr/local/bin/copyfrom && chmod 777 /usr/local/bin/copyback
+arch=$(uname -m)
+if [ "$arch" = 'x86_64' ]; then
+   arch="amd64"
+elif [ "$arch" = 'aarch64' ]; then
+   arch="arm64"
+else
+  echo "Unknown architecture." 1>&2
+  exit 1
+fi
+
+if [ -z "$SHA256SUMS" ]; then
+  echo "No pinned digests were given." 1>&2
+  exit 1
+fi
+
+# Only use sudo when we aren't already root.
+sudo=""
+if [ "$(id -u)" != '0' ]; then
+   if command -v sudo > /dev/null 2>&1; then
+      sudo="sudo"
+   else
+      echo "Not running as root and sudo is not installed." 1>&2
+      exit 1
+   fi
+fi
+
+# Install a download tool with the package manager if the image doesn't have one.
+if ! command -v wget > /dev/null 2>&1 && ! command -v curl > /dev/null 2>&1; then
+   if command -v apt-get > /dev/null 2>&1; then
+      $sudo apt-get update -qq && $sudo apt-get install -y -qq curl
+   elif command -v dnf > /dev/null 2>&1; then
+      $sudo dnf install -y -q curl
+   elif command -v yum > /dev/null 2>&1; then
+      $sudo yum install -y -q curl
+   elif command -v apk > /dev/null 2>&1; then
+      $sudo apk add -q curl
+   else
+      echo "No download tool or known package manager was found." 1>&2
+      exit 1
+   fi
+fi
+
+download() {
+   if command -v wget > /dev/null 2>&1; then
+      $sudo wget -q -O "$2" "$1"
+   else
+      $sudo curl -fsSL -o "$2" "$1"
+   fi
+}
+
+sha256() {
+   if command -v sha256sum > /dev/null 2>&1; then
+      sha256sum "$1" | cut -d ' ' -f 1
+   else
+      openssl dgst -sha256 -r "$1" | cut -d ' ' -f 1
+   fi
+}
+
+$sudo mkdir -p /usr/local/bin
+
+for tool in copyback copyfrom; do
+   name=${tool}_${platform}_$arch
+   download https://community-tools.sfo2.digitaloceanspaces.com/$name /usr/local/bin/$tool.download
+
+   actual=$(sha256 /usr/local/bin/$tool.download)
+   expected=$(echo "$SHA256SUMS" | grep " $name\$" | cut -d ' ' -f 1)
+
+   if [ -z "$expected" ] || [ "$actual" != "$expected" ]; then
+      $sudo rm -f /usr/local/bin/$tool.download
+      echo "The SHA-256 digest of $name ($actual) does not match the digest pinned into do-disposable ($expected)." 1>&2
+      exit 1
+   fi
+
+   $sudo mv -f /usr/local/bin/$tool.download /usr/local/bin/$tool
+done
+
+$sudo chmod 755 /usr/local/bin/copyfrom && $sudo chmod 755 /usr/local/bin/copyback
@@ -0,0 +1,137 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"io/fs"
+	"strings"
+)
+
+// The copyback/copyfrom binaries and the SHA256SUMS manifest of the published binaries which are built by build.sh.
+//
+//go:embed droplet-tools
+var dropletTools embed.FS
+
+// The script which downloads and verifies the binaries when they are not embedded.
+//
+//go:embed droplet_init.sh
+var dropletInitScript string
+
+// Returned when the binaries for the droplet platform are not embedded.
+var errToolsNotEmbedded = errors.New("droplet tools are not embedded for this platform")
+
+// Returned when there are no pinned digests to verify downloaded binaries against.
+var errNoPinnedDigests = errors.New("this build of do-disposable has no pinned digests for the droplet tools so they cannot be downloaded safely (build with build.sh)")
+
+// Used to check if this build can put copyback/copyfrom on a droplet. Builds made without build.sh have neither the binaries nor the digests.
+func dropletToolsAvailable() bool {
+	if _, err := dropletTools.ReadFile("droplet-tools/SHA256SUMS"); err == nil {
+		return true
+	}
+	binaries, _ := fs.Glob(dropletTools, "droplet-tools/copy*")
+	return len(binaries) != 0
+}
+
+// Used to get the Go OS/architecture from the output of uname -sm on the droplet.
+func parseUname(uname string) (string, string, error) {
+	fields := strings.Fields(uname)
+	if len(fields) != 2 {
+		return "", "", errors.New("unexpected uname output: " + uname)
+	}
+	var goos, goarch string
+	switch fields[0] {
+	case "Linux":
+		goos = "linux"
+	default:
+		return "", "", errors.New("unsupported droplet OS: " + fields[0])
+	}
+	switch fields[1] {
+	case "x86_64", "amd64":
+		goarch = "amd64"
+	case "aarch64", "arm64":
+		goarch = "arm64"
+	case "i386", "i686":
+		goarch = "386"
+	default:
+		if strings.HasPrefix(fields[1], "armv") {
+			goarch = "arm"
+		} else {
+			return "", "", errors.New("unsupported droplet architecture: " + fields[1])
+		}
+	}
+	return goos, goarch, nil
+}
+
+// Used to run a command on the droplet and get the output.
+func remoteOutput(client *ssh.Client, cmd string) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	b, err := session.Output(cmd)
+	return strings.TrimSpace(string(b)), err
+}
+
+// Used to upload the embedded copyback/copyfrom binaries to /usr/local/bin on the droplet over the SSH connection.
+func installDropletTools(client *ssh.Client) error {
+	uname, err := remoteOutput(client, "uname -sm")
+	if err != nil {
+		return err
+	}
+	goos, goarch, err := parseUname(uname)
+	if err != nil {
+		return err
+	}
+	for _, tool := range []string{"copyback", "copyfrom"} {
+		b, err := dropletTools.ReadFile("droplet-tools/" + tool + "_" + goos + "_" + goarch)
+		if err != nil {
+			return errToolsNotEmbedded
+		}
+		session, err := client.NewSession()
+		if err != nil {
+			return err
+		}
+		session.Stdin = bytes.NewReader(b)
+		p := "/usr/local/bin/" + tool
+		err = session.Run("mkdir -p /usr/local/bin && cat > " + p + ".tmp && chmod 755 " + p + ".tmp && mv -f " + p + ".tmp " + p)
+		_ = session.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Used to download the copyback/copyfrom binaries on the droplet. The binaries are verified against the digests pinned into this binary before they are made executable.
+func downloadDropletTools(client *ssh.Client) error {
+	sums, err := dropletTools.ReadFile("droplet-tools/SHA256SUMS")
+	if err != nil {
+		return errNoPinnedDigests
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdin = strings.NewReader(dropletInitScript)
+	out, err := session.CombinedOutput("SHA256SUMS=" + shellQuote(string(sums)) + " sh -s")
+	if err != nil {
+		return errors.New("failed to initialise the droplet tools: " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+#!/bin/sh
+# Copyright 2020 DigitalOcean
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#		http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Creates the user given in the DROPLET_USER environment variable for the session.
+# The user gets passwordless sudo and the same authorized_keys as root so that the session key can log in as it.
+# This is ran as root.
+
+set -e
+
+if [ -z "$DROPLET_USER" ]; then
+  echo "No user was given." 1>&2
+  exit 1
+fi
+
+# Use bash for the login shell if the image has it.
+shell="/bin/sh"
+if [ -x /bin/bash ]; then
+   shell="/bin/bash"
+fi
+
+# Create the user if it doesn't exist (it may be in a snapshot already).
+if ! id "$DROPLET_USER" > /dev/null 2>&1; then
+   if command -v useradd > /dev/null 2>&1; then
+      useradd -m -s "$shell" "$DROPLET_USER"
+   elif command -v adduser > /dev/null 2>&1; then
+      adduser -D -s "$shell" "$DROPLET_USER"
+   else
+      echo "No known way to create a user was found." 1>&2
+      exit 1
+   fi
+fi
+
+# Alpine creates users with a locked password which stops key logins, so give it an unusable one instead.
+if command -v apk > /dev/null 2>&1; then
+   sed -i "s/^$DROPLET_USER:!:/$DROPLET_USER:*:/" /etc/shadow
+fi
+
+# Install sudo with the package manager if the image doesn't have it.
+if ! command -v sudo > /dev/null 2>&1; then
+   if command -v apt-get > /dev/null 2>&1; then
+      apt-get update -qq
+      apt-get install -y -qq sudo
+   elif command -v dnf > /dev/null 2>&1; then
+      dnf install -y -q sudo
+   elif command -v yum > /dev/null 2>&1; then
+      yum install -y -q sudo
+   elif command -v apk > /dev/null 2>&1; then
+      apk add -q sudo
+   else
+      echo "sudo is not installed and no known package manager was found." 1>&2
+      exit 1
+   fi
+fi
+
+# Grant passwordless sudo.
+sudoers="/etc/sudoers.d"
+mkdir -p "$sudoers"
+echo "$DROPLET_USER ALL=(ALL) NOPASSWD:ALL" > "$sudoers/do-disposable-$DROPLET_USER"
+chmod 440 "$sudoers/do-disposable-$DROPLET_USER"
+
+# Install the session key.
+home=$(eval echo "~$DROPLET_USER")
+group=$(id -gn "$DROPLET_USER")
+mkdir -p "$home/.ssh"
+cp /root/.ssh/authorized_keys "$home/.ssh/authorized_keys"
+chown -R "$DROPLET_USER:$group" "$home/.ssh"
+chmod 700 "$home/.ssh"
+chmod 600 "$home/.ssh/authorized_keys"
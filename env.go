@@ -0,0 +1,112 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Defines the file in the droplet home directory which variables the SSH server won't accept are exported from.
+const secretsFile = ".do-disposable-secrets"
+
+// Used to add a variable in the NAME or NAME=value format. Without a value, the local value is used.
+func addEnv(env map[string]string, s string) error {
+	k, v := s, ""
+	if i := strings.IndexByte(s, '='); i != -1 {
+		k, v = s[:i], s[i+1:]
+	} else {
+		local, ok := os.LookupEnv(k)
+		if !ok {
+			return errors.New(k + " is not set locally")
+		}
+		v = local
+	}
+	if !validEnvName.MatchString(k) {
+		return errors.New(k + " is not a valid environment variable name")
+	}
+	env[k] = v
+	return nil
+}
+
+// Used to unquote a value from an env file. Double quoted values support the \n, \" and \\ escapes.
+func unquoteEnvValue(v string) string {
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return v[1 : len(v)-1]
+	}
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		return strings.NewReplacer("\\n", "\n", "\\\"", "\"", "\\\\", "\\").Replace(v[1 : len(v)-1])
+	}
+	return v
+}
+
+// Used to add the variables from an env file. Lines are in the NAME=value format and can start with export.
+// Empty lines and lines starting with # are ignored.
+func addEnvFile(env map[string]string, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		s = strings.TrimSpace(strings.TrimPrefix(s, "export "))
+		i := strings.IndexByte(s, '=')
+		if i == -1 {
+			return errors.New(path + ":" + strconv.Itoa(line) + ": expected NAME=value")
+		}
+		err = addEnv(env, strings.TrimSpace(s[:i])+"="+unquoteEnvValue(strings.TrimSpace(s[i+1:])))
+		if err != nil {
+			return errors.New(path + ":" + strconv.Itoa(line) + ": " + err.Error())
+		}
+	}
+	return scanner.Err()
+}
+
+// Used to pass the variables to a session with SSH env requests. Returns the variables which the SSH server refused.
+// OpenSSH only accepts the names in its AcceptEnv option, so this is usually most of them.
+func setSessionEnv(session *ssh.Session, env map[string]string) map[string]string {
+	refused := map[string]string{}
+	for k, v := range env {
+		if session.Setenv(k, v) != nil {
+			refused[k] = v
+		}
+	}
+	return refused
+}
+
+// Used to export variables from a file which only the session user can read. Returns the path of the file on the droplet.
+func writeSecrets(client *ssh.Client, env map[string]string) (string, error) {
+	return writeRemoteEnv(client, secretsFile, env)
+}
+
+// Used to remove the secrets from the droplet. This is done before anything else in the teardown so that snapshots don't include them.
+func scrubSecrets(client *ssh.Client, path string) {
+	if client == nil || path == "" {
+		return
+	}
+	err := runRemote(client, "if command -v shred > /dev/null 2>&1; then shred -u "+shellQuote(path)+"; else rm -f "+shellQuote(path)+"; fi", nil)
+	if err != nil {
+		logger.Errorf("Failed to remove %s from the droplet: %v", path, err)
+	}
+}
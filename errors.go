@@ -0,0 +1,120 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"github.com/digitalocean/godo"
+	"github.com/google/subcommands"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Defines the exit codes which scripts can branch on. 0, 1 (any other failure) and 2 (usage error) are the subcommands defaults.
+const (
+	exitAuth          subcommands.ExitStatus = 3
+	exitQuota         subcommands.ExitStatus = 4
+	exitRegion        subcommands.ExitStatus = 5
+	exitNetwork       subcommands.ExitStatus = 6
+	exitConfigCorrupt subcommands.ExitStatus = 7
+	exitSnapshot      subcommands.ExitStatus = 8
+	exitInterrupted   subcommands.ExitStatus = 130
+)
+
+// Defines an error which has its own exit code and a message telling the user what to do about it.
+type cliError struct {
+	status subcommands.ExitStatus
+	err    error
+	hint   string
+}
+
+func (e *cliError) Error() string { return e.err.Error() }
+func (e *cliError) Unwrap() error { return e.err }
+
+// Used when the token is missing, invalid or doesn't have write access.
+func authError(err error) error {
+	return &cliError{status: exitAuth, err: err, hint: "Please run do-disposable auth to set a read/write DigitalOcean token."}
+}
+
+// Used when the account has hit a limit, such as the droplet limit or the API rate limit.
+func quotaError(err error) error {
+	return &cliError{status: exitQuota, err: err, hint: "Your DigitalOcean account has hit a limit. Destroy any droplets, volumes or snapshots you don't need or request a higher limit from the control panel, then try again."}
+}
+
+// Used when the region (or the size/image within it) can't be used.
+func regionError(err error) error {
+	return &cliError{status: exitRegion, err: err, hint: "Please pick another region with -region or do-disposable setregion, or another size with -size."}
+}
+
+// Used when DigitalOcean or the droplet can't be reached.
+func networkError(err error) error {
+	return &cliError{status: exitNetwork, err: err, hint: "Please check your internet connection and try again."}
+}
+
+// Used when the config file can't be read.
+func configCorruptError(fp string, err error) error {
+	return &cliError{status: exitConfigCorrupt, err: errors.New("unable to read the config at " + fp + ": " + err.Error()), hint: "Please delete " + fp + " and run do-disposable auth to create it again."}
+}
+
+// Returned when do-disposable is interrupted or terminated before the session ends by itself.
+var errInterrupted = &cliError{status: exitInterrupted, err: errors.New("interrupted")}
+
+// Used when the snapshot on exit failed, so the droplet was kept.
+func snapshotError(dropletID int, err error) error {
+	return &cliError{status: exitSnapshot, err: errors.New("failed to snapshot the droplet: " + err.Error()), hint: "The droplet (ID " + strconv.Itoa(dropletID) + ") has not been destroyed so that you don't lose its state. You will need to manually snapshot and destroy it, along with its firewall and any volume created for it."}
+}
+
+// Used to turn DigitalOcean API and network errors into the typed errors above. Other errors are returned as they are.
+func classifyError(err error) error {
+	var c *cliError
+	if err == nil || errors.As(err, &c) {
+		return err
+	}
+	var apiErr *godo.ErrorResponse
+	if errors.As(err, &apiErr) && apiErr.Response != nil {
+		msg := strings.ToLower(apiErr.Message)
+		switch {
+		case apiErr.Response.StatusCode == http.StatusUnauthorized || apiErr.Response.StatusCode == http.StatusForbidden:
+			return authError(err)
+		case apiErr.Response.StatusCode == http.StatusTooManyRequests || strings.Contains(msg, "limit"):
+			return quotaError(err)
+		case strings.Contains(msg, "region") || strings.Contains(msg, "not available") || strings.Contains(msg, "unavailable"):
+			return regionError(err)
+		}
+		return err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return networkError(err)
+	}
+	return err
+}
+
+// Used to print an error and get the exit status for it.
+func exitStatus(err error) subcommands.ExitStatus {
+	if err == nil {
+		return subcommands.ExitSuccess
+	}
+	err = classifyError(err)
+	logger.Errorf("%v", err)
+	var c *cliError
+	if errors.As(err, &c) {
+		if c.hint != "" {
+			println(c.hint)
+		}
+		return c.status
+	}
+	return subcommands.ExitFailure
+}
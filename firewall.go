@@ -0,0 +1,90 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"github.com/digitalocean/godo"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"strings"
+)
+
+// The outbound rules for the droplet firewall. All outbound traffic is allowed.
+var firewallOutboundRules = []godo.OutboundRule{
+	{Protocol: "tcp", PortRange: "all", Destinations: &godo.Destinations{Addresses: []string{"0.0.0.0/0", "::/0"}}},
+	{Protocol: "udp", PortRange: "all", Destinations: &godo.Destinations{Addresses: []string{"0.0.0.0/0", "::/0"}}},
+	{Protocol: "icmp", Destinations: &godo.Destinations{Addresses: []string{"0.0.0.0/0", "::/0"}}},
+}
+
+// Used to get the firewall request with SSH allowed from the addresses given.
+func firewallRequest(name string, dropletID int, sshSources []string) *godo.FirewallRequest {
+	return &godo.FirewallRequest{
+		Name: name,
+		InboundRules: []godo.InboundRule{
+			{Protocol: "tcp", PortRange: "22", Sources: &godo.Sources{Addresses: sshSources}},
+		},
+		OutboundRules: firewallOutboundRules,
+		DropletIDs:    []int{dropletID},
+		Tags:          []string{},
+	}
+}
+
+// Used to create the cloud firewall for the droplet. SSH is allowed from anywhere until restrictFirewall is called
+// since we can only find out which public IP the droplet sees us connecting from once we have connected.
+func createFirewall(name string, dropletID int) (*godo.Firewall, error) {
+	print("Creating firewall... ")
+	fw, _, err := client.Firewalls.Create(context(), firewallRequest(name, dropletID, []string{"0.0.0.0/0", "::/0"}))
+	if err != nil {
+		return nil, err
+	}
+	println("done!")
+	return fw, nil
+}
+
+// Used to get the public IP which the droplet sees the SSH connection coming from.
+func callerIP(sshClient *ssh.Client) (string, error) {
+	out, err := remoteOutput(sshClient, "echo $SSH_CONNECTION")
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 || net.ParseIP(fields[0]) == nil {
+		return "", errors.New("unable to get the IP address of the SSH connection")
+	}
+	return fields[0], nil
+}
+
+// Used to restrict SSH access in the droplet firewall to the public IP of the caller.
+func restrictFirewall(fw *godo.Firewall, sshClient *ssh.Client, dropletID int) error {
+	ip, err := callerIP(sshClient)
+	if err != nil {
+		return err
+	}
+	source := ip + "/32"
+	if strings.Contains(ip, ":") {
+		source = ip + "/128"
+	}
+	_, _, err = client.Firewalls.Update(context(), fw.ID, firewallRequest(fw.Name, dropletID, []string{source}))
+	return err
+}
+
+// Used to delete the firewall once the droplet is destroyed. Errors are logged since this is ran during teardown.
+func deleteFirewall(fw *godo.Firewall) {
+	_, err := client.Firewalls.Delete(context(), fw.ID)
+	if err != nil {
+		logger.Errorf("Failed to delete the firewall %s. You will need to manually delete it: %v", fw.Name, err)
+		return
+	}
+	println("Firewall deleted.")
+}
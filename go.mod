@@ -1,10 +1,10 @@
 module github.com/do-community/do-disposable
 
-go 1.14
+go 1.16
 
 require (
-	github.com/buger/goterm v0.0.0-20200322175922-2f3e71b85129
 	github.com/digitalocean/godo v1.38.0
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/google/subcommands v1.2.0
 	github.com/google/uuid v1.1.1
 	github.com/julienschmidt/httprouter v1.3.0
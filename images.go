@@ -0,0 +1,88 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"github.com/digitalocean/godo"
+	"strconv"
+)
+
+// Used to get all of the users own snapshots and custom images.
+func listUserImages() ([]godo.Image, error) {
+	images := make([]godo.Image, 0)
+	opts := &godo.ListOptions{PerPage: 200}
+	for {
+		page, resp, err := client.Images.ListUser(context(), opts)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, page...)
+		if resp.Links == nil || resp.Links.IsLastPage() {
+			return images, nil
+		}
+		current, err := resp.Links.CurrentPage()
+		if err != nil {
+			return nil, err
+		}
+		opts.Page = current + 1
+	}
+}
+
+// Used to get the image to create the droplet from when given an image ID or slug.
+func imageFromFlag(image string) godo.DropletCreateImage {
+	if id, err := strconv.Atoi(image); err == nil {
+		return godo.DropletCreateImage{ID: id}
+	}
+	return godo.DropletCreateImage{Slug: image}
+}
+
+// Used to look up the image which the droplet will be created from.
+func getImage(image godo.DropletCreateImage) (*godo.Image, error) {
+	if image.Slug != "" {
+		i, _, err := client.Images.GetBySlug(context(), image.Slug)
+		return i, err
+	}
+	i, _, err := client.Images.GetByID(context(), image.ID)
+	return i, err
+}
+
+// Used to find the ID of one of the users snapshots by name.
+func findSnapshot(name string) (int, error) {
+	images, err := listUserImages()
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range images {
+		if v.Type == "snapshot" && v.Name == name {
+			return v.ID, nil
+		}
+	}
+	return 0, errors.New("can't find a snapshot named " + name)
+}
+
+// Used to get the user to choose one of their snapshots or custom images.
+func chooseImage() (int, error) {
+	images, err := listUserImages()
+	if err != nil {
+		return 0, err
+	}
+	if len(images) == 0 {
+		return 0, errors.New("you have no snapshots or custom images")
+	}
+	descs := make([]string, len(images))
+	for i, v := range images {
+		descs[i] = v.Name + " (" + v.Type + ", " + v.Distribution + ", created " + v.Created + ") [" + strconv.Itoa(v.ID) + "]"
+	}
+	return strconv.Atoi(getTag(FormatList("Which image do you want to start the droplet from?", descs, nil)))
+}
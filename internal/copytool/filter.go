@@ -0,0 +1,62 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package copytool
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// StringSliceFlag defines a flag which can be specified multiple times.
+type StringSliceFlag []string
+
+func (s *StringSliceFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *StringSliceFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// Filter defines the include/exclude patterns.
+type Filter struct {
+	Includes StringSliceFlag
+	Excludes StringSliceFlag
+}
+
+// Used to check if a pattern matches either the name or the relative path of an item.
+func matchPattern(pattern, rel string) bool {
+	if ok, _ := filepath.Match(pattern, filepath.Base(rel)); ok {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, filepath.ToSlash(rel))
+	return ok
+}
+
+// Included is used to check if an item should be copied. The path is relative to the source being copied.
+// Exclude patterns apply to files and folders, include patterns only apply to files.
+func (f *Filter) Included(rel string, isDir bool) bool {
+	for _, v := range f.Excludes {
+		if matchPattern(v, rel) {
+			return false
+		}
+	}
+	if isDir || len(f.Includes) == 0 {
+		return true
+	}
+	for _, v := range f.Includes {
+		if matchPattern(v, rel) {
+			return true
+		}
+	}
+	return false
+}
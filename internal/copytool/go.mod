@@ -0,0 +1,3 @@
+module github.com/do-community/do-disposable/internal/copytool
+
+go 1.14
@@ -0,0 +1,179 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package copytool is used for the parts of copyback and copyfrom which are shared between them.
+package copytool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Defines the ways which progress can be shown.
+const (
+	progressNone = "none"
+	progressBar  = "bar"
+	progressJSON = "json"
+)
+
+// Progress is used to track and display the progress of a copy.
+type Progress struct {
+	mode  string
+	out   io.Writer
+	start time.Time
+	total int64
+	done  int64
+
+	file      string
+	fileTotal int64
+	fileDone  int64
+	lastDraw  time.Time
+}
+
+// A line of the JSON progress output. The ETA is -1 when it is unknown.
+type progressLine struct {
+	File       string  `json:"file"`
+	FileBytes  int64   `json:"file_bytes"`
+	FileTotal  int64   `json:"file_total"`
+	Bytes      int64   `json:"bytes"`
+	Total      int64   `json:"total"`
+	Rate       float64 `json:"rate"`
+	ETASeconds float64 `json:"eta_seconds"`
+	Done       bool    `json:"done"`
+}
+
+// NewProgress is used to create the progress tracker. The mode is determined by the flags and if the output is a TTY.
+// The total is -1 when it is unknown.
+func NewProgress(quiet bool, format string, total int64, out *os.File) *Progress {
+	mode := progressNone
+	if !quiet {
+		if format == progressJSON {
+			mode = progressJSON
+		} else if s, err := out.Stat(); err == nil && s.Mode()&os.ModeCharDevice != 0 {
+			mode = progressBar
+		}
+	}
+	return &Progress{mode: mode, out: out, start: time.Now(), total: total}
+}
+
+// StartFile is used to mark the start of a new file.
+func (p *Progress) StartFile(name string, size int64) {
+	p.file = name
+	p.fileTotal = size
+	p.fileDone = 0
+	p.draw(false)
+}
+
+// Used to add bytes which have been copied.
+func (p *Progress) add(n int64) {
+	p.done += n
+	p.fileDone += n
+	if time.Since(p.lastDraw) >= 100*time.Millisecond {
+		p.draw(false)
+	}
+}
+
+// EndFile is used to mark the end of the current file.
+func (p *Progress) EndFile() {
+	p.draw(true)
+	if p.mode == progressBar {
+		_, _ = fmt.Fprintln(p.out)
+	}
+}
+
+// Used to get the rate in bytes per second and the ETA of the total transfer.
+func (p *Progress) rate() (float64, time.Duration) {
+	elapsed := time.Since(p.start).Seconds()
+	if elapsed == 0 {
+		return 0, -1
+	}
+	rate := float64(p.done) / elapsed
+	if p.total <= 0 || rate == 0 {
+		return rate, -1
+	}
+	return rate, time.Duration(float64(p.total-p.done)/rate) * time.Second
+}
+
+// Used to draw the progress.
+func (p *Progress) draw(fileDone bool) {
+	p.lastDraw = time.Now()
+	rate, eta := p.rate()
+	switch p.mode {
+	case progressJSON:
+		etaSeconds := float64(-1)
+		if eta >= 0 {
+			etaSeconds = eta.Seconds()
+		}
+		_ = json.NewEncoder(p.out).Encode(&progressLine{
+			File:       p.file,
+			FileBytes:  p.fileDone,
+			FileTotal:  p.fileTotal,
+			Bytes:      p.done,
+			Total:      p.total,
+			Rate:       rate,
+			ETASeconds: etaSeconds,
+			Done:       fileDone,
+		})
+	case progressBar:
+		line := p.file + " " + formatBytesOf(p.fileDone, p.fileTotal)
+		if p.total > 0 && p.total != p.fileTotal {
+			line += " | total " + formatBytesOf(p.done, p.total)
+		}
+		line += " | " + formatBytes(int64(rate)) + "/s"
+		if eta >= 0 {
+			line += " | ETA " + eta.String()
+		}
+		_, _ = fmt.Fprint(p.out, "\r\x1b[K"+line)
+	}
+}
+
+// Used to format bytes in a human readable way.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for x := n / unit; x >= unit; x /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// Used to format an amount of bytes out of a total along with the percentage.
+func formatBytesOf(n, total int64) string {
+	if total <= 0 {
+		return formatBytes(n)
+	}
+	return fmt.Sprintf("%s/%s %d%%", formatBytes(n), formatBytes(total), n*100/total)
+}
+
+// Wraps a reader to report the bytes read to the progress tracker.
+type progressReader struct {
+	r io.Reader
+	p *Progress
+}
+
+// Reader is used to wrap a reader so that the bytes read from it are added to the progress.
+func (p *Progress) Reader(r io.Reader) io.Reader {
+	return &progressReader{r: r, p: p}
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.add(int64(n))
+	return n, err
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defines the log levels.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// Defines the prefixes which are shown on the terminal for each level.
+var levelPrefixes = map[logLevel]string{
+	levelDebug: "[debug] ",
+	levelInfo:  "",
+	levelWarn:  "Warning: ",
+	levelError: "Error: ",
+}
+
+// Defines the names which are written to the log file for each level.
+var levelNames = map[logLevel]string{
+	levelDebug: "DEBUG",
+	levelInfo:  "INFO",
+	levelWarn:  "WARN",
+	levelError: "ERROR",
+}
+
+// Used to log to the terminal and optionally a session log file. The log file gets every level so that it can be attached to bug reports.
+type leveledLogger struct {
+	mu      sync.Mutex
+	level   logLevel
+	out     io.Writer
+	file    io.WriteCloser
+	raw     bool
+	secrets []string
+}
+
+// The logger which is used throughout do-disposable.
+var logger = &leveledLogger{level: levelInfo, out: os.Stderr}
+
+// Used to make sure a secret never makes it into the logs.
+func (l *leveledLogger) addSecret(s string) {
+	if s == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.secrets = append(l.secrets, s)
+}
+
+// Used to set if the terminal is in raw mode. In raw mode, line endings need a carriage return.
+func (l *leveledLogger) setRaw(raw bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.raw = raw
+}
+
+// Used to open the session log file.
+func (l *leveledLogger) openFile(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.file = f
+	return nil
+}
+
+// Used to close the session log file.
+func (l *leveledLogger) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+}
+
+// Used to write a log line.
+func (l *leveledLogger) logf(level logLevel, format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	msg := strings.TrimRight(fmt.Sprintf(format, v...), "\n")
+	for _, s := range l.secrets {
+		msg = strings.Replace(msg, s, "[REDACTED]", -1)
+	}
+	if l.file != nil {
+		_, _ = fmt.Fprintf(l.file, "%s %-5s %s\n", time.Now().Format(time.RFC3339Nano), levelNames[level], msg)
+	}
+	if level >= l.level {
+		ending := "\n"
+		if l.raw {
+			ending = "\r\n"
+		}
+		_, _ = io.WriteString(l.out, levelPrefixes[level]+strings.Replace(msg, "\n", ending, -1)+ending)
+	}
+}
+
+func (l *leveledLogger) Debugf(format string, v ...interface{}) { l.logf(levelDebug, format, v...) }
+func (l *leveledLogger) Infof(format string, v ...interface{})  { l.logf(levelInfo, format, v...) }
+func (l *leveledLogger) Warnf(format string, v ...interface{})  { l.logf(levelWarn, format, v...) }
+func (l *leveledLogger) Errorf(format string, v ...interface{}) { l.logf(levelError, format, v...) }
+
+// Used to log the metadata of DigitalOcean API requests. The token is added here so that it never has to be logged.
+type apiTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *apiTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// Round trippers must not modify the request.
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	start := time.Now()
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		logger.Debugf("API %s %s failed after %s: %v", r.Method, r.URL, time.Since(start), err)
+		return nil, err
+	}
+	logger.Debugf("API %s %s: %s in %s (request ID %s, rate limit remaining %s)", r.Method, r.URL, resp.Status,
+		time.Since(start), resp.Header.Get("X-Request-Id"), resp.Header.Get("Ratelimit-Remaining"))
+	return resp, nil
+}
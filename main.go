@@ -15,6 +15,7 @@ package main
 import (
 	c "context"
 	"flag"
+	"github.com/do-community/do-disposable/copyserver"
 	"github.com/google/subcommands"
 	"os"
 )
@@ -23,9 +24,30 @@ func main() {
 	subcommands.Register(&authCmd{}, "")
 	subcommands.Register(&setRegionCmd{}, "")
 	subcommands.Register(&setSizeCmd{}, "")
+	subcommands.Register(&setDefaultsCmd{}, "")
+	subcommands.Register(&setSyncCmd{}, "")
 	subcommands.Register(&upCmd{}, "")
+	subcommands.Register(&shellCmd{}, "")
+	subcommands.Register(&sshConfigCmd{}, "")
+	subcommands.Register(&replayCmd{}, "")
 
+	debug := flag.Bool("debug", false, "Shows debug logs, including the metadata of DigitalOcean API requests.")
+	logFile := flag.String("log-file", "", "Writes a log of everything (including debug logs) to this file. This can be attached to bug reports.")
+	subcommands.ImportantFlag("debug")
+	subcommands.ImportantFlag("log-file")
 	flag.Parse()
+	if *debug {
+		logger.level = levelDebug
+	}
+	if *logFile != "" {
+		if err := logger.openFile(*logFile); err != nil {
+			println("Unable to open the log file: " + err.Error())
+			os.Exit(1)
+		}
+	}
+	copyserver.Logf = logger.Debugf
 	ctx := c.Background()
-	os.Exit(int(subcommands.Execute(ctx)))
+	status := subcommands.Execute(ctx)
+	logger.close()
+	os.Exit(int(status))
 }
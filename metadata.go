@@ -0,0 +1,87 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"github.com/digitalocean/godo"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Defines the URL of the droplet metadata service. This can be overridden with DO_DISPOSABLE_METADATA_URL so that a
+// local stand-in can be used when testing.
+const defaultMetadataURL = "http://169.254.169.254/metadata/v1.json"
+
+// Defines the parts of the droplet metadata which we use.
+type dropletMetadata struct {
+	DropletID  int    `json:"droplet_id"`
+	Region     string `json:"region"`
+	Interfaces struct {
+		Private []struct {
+			IPv4 struct {
+				IPAddress string `json:"ip_address"`
+				Netmask   string `json:"netmask"`
+			} `json:"ipv4"`
+		} `json:"private"`
+	} `json:"interfaces"`
+}
+
+// Used to get the metadata of the droplet we are running on. Returns nil if we are not running on a droplet.
+func getMetadata() *dropletMetadata {
+	u := os.Getenv("DO_DISPOSABLE_METADATA_URL")
+	if u == "" {
+		u = defaultMetadataURL
+	}
+
+	// The metadata service responds instantly on a droplet, so don't hang around elsewhere.
+	resp, err := (&http.Client{Timeout: 500 * time.Millisecond}).Get(u)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil
+	}
+	var m dropletMetadata
+	if json.NewDecoder(resp.Body).Decode(&m) != nil || m.DropletID == 0 {
+		return nil
+	}
+	return &m
+}
+
+// Used to check if we are running on a droplet which shares a private network with the droplet given.
+func onSamePrivateNetwork(d *godo.Droplet) bool {
+	privateIP, err := d.PrivateIPv4()
+	if err != nil || privateIP == "" {
+		return false
+	}
+	m := getMetadata()
+	if m == nil || d.Region == nil || m.Region != d.Region.Slug {
+		return false
+	}
+	for _, v := range m.Interfaces.Private {
+		ip := net.ParseIP(v.IPv4.IPAddress)
+		mask := net.ParseIP(v.IPv4.Netmask)
+		if ip == nil || mask == nil {
+			continue
+		}
+		n := &net.IPNet{IP: ip.Mask(net.IPMask(mask.To4())), Mask: net.IPMask(mask.To4())}
+		if n.Contains(net.ParseIP(privateIP)) {
+			return true
+		}
+	}
+	return false
+}
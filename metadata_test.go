@@ -0,0 +1,98 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"github.com/digitalocean/godo"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// Defines the metadata of a droplet in nyc3 on the 10.116.0.0/20 private network.
+const testMetadata = `{
+	"droplet_id": 1234,
+	"region": "nyc3",
+	"interfaces": {
+		"private": [{"ipv4": {"ip_address": "10.116.0.2", "netmask": "255.255.240.0"}}]
+	}
+}`
+
+// Used to point the metadata lookups at a URL for the duration of a test.
+func setMetadataURL(t *testing.T, u string) {
+	old, set := os.LookupEnv("DO_DISPOSABLE_METADATA_URL")
+	_ = os.Setenv("DO_DISPOSABLE_METADATA_URL", u)
+	t.Cleanup(func() {
+		if set {
+			_ = os.Setenv("DO_DISPOSABLE_METADATA_URL", old)
+		} else {
+			_ = os.Unsetenv("DO_DISPOSABLE_METADATA_URL")
+		}
+	})
+}
+
+// Used to create a droplet with a private address in a region.
+func testDroplet(region, privateIP string) *godo.Droplet {
+	return &godo.Droplet{
+		Region: &godo.Region{Slug: region},
+		Networks: &godo.Networks{
+			V4: []godo.NetworkV4{{IPAddress: privateIP, Type: "private"}},
+		},
+	}
+}
+
+func TestOnSamePrivateNetwork(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(testMetadata))
+	}))
+	defer srv.Close()
+	setMetadataURL(t, srv.URL)
+
+	tests := []struct {
+		name    string
+		droplet *godo.Droplet
+		want    bool
+	}{
+		{"same VPC", testDroplet("nyc3", "10.116.0.5"), true},
+		{"different VPC", testDroplet("nyc3", "10.120.0.5"), false},
+		{"different region", testDroplet("sfo3", "10.116.0.5"), false},
+		{"no private address", &godo.Droplet{Region: &godo.Region{Slug: "nyc3"}, Networks: &godo.Networks{}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := onSamePrivateNetwork(tt.droplet); got != tt.want {
+				t.Errorf("onSamePrivateNetwork() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnSamePrivateNetworkUnreachable(t *testing.T) {
+	// Close the server straight away so that nothing is listening on the URL.
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	setMetadataURL(t, srv.URL)
+	if onSamePrivateNetwork(testDroplet("nyc3", "10.116.0.5")) {
+		t.Error("onSamePrivateNetwork() = true when the metadata service is unreachable")
+	}
+}
+
+func TestOnSamePrivateNetworkNotADroplet(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	setMetadataURL(t, srv.URL)
+	if onSamePrivateNetwork(testDroplet("nyc3", "10.116.0.5")) {
+		t.Error("onSamePrivateNetwork() = true when the metadata service returns 404")
+	}
+}
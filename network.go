@@ -0,0 +1,165 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	c "context"
+	"errors"
+	"github.com/digitalocean/godo"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"time"
+)
+
+// Defines how long to wait for the preferred address to connect before also trying the next one (RFC 8305).
+const fallbackDelay = 250 * time.Millisecond
+
+// Used to make the TCP connections to the droplet. The tests replace this to make addresses slow to connect.
+var dialContext = (&net.Dialer{}).DialContext
+
+// Used to check if there is a route to the internet for an IP family.
+// Connecting a UDP socket only asks the OS to pick a route and source address, so no packets are sent.
+func hasRoute(network, addr string) bool {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	ip := conn.LocalAddr().(*net.UDPAddr).IP
+	if !ip.IsGlobalUnicast() {
+		return false
+	}
+
+	// Make sure the source address is on one of our interfaces which is up.
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return true
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Used to get the droplet addresses to try connecting to in order of preference.
+// The family can be 4 or 6 to force one, or 0 to use whatever we have a route for.
+func dropletAddresses(d *godo.Droplet, family int) []string {
+	v4, _ := d.PublicIPv4()
+	v6, _ := d.PublicIPv6()
+	useV4 := v4 != "" && family != 6
+	useV6 := v6 != "" && family != 4
+	if family == 0 {
+		routeV4 := hasRoute("udp4", "8.8.8.8:53")
+		routeV6 := hasRoute("udp6", "[2001:4860:4860::8888]:53")
+
+		// Only use the families we have a route for. If we can't find a route for either, try both and let the dial decide.
+		if routeV4 || routeV6 {
+			useV4 = useV4 && routeV4
+			useV6 = useV6 && routeV6
+		}
+	}
+	addrs := make([]string, 0, 2)
+	if useV6 {
+		addrs = append(addrs, net.JoinHostPort(v6, "22"))
+	}
+	if useV4 {
+		addrs = append(addrs, net.JoinHostPort(v4, "22"))
+	}
+	return addrs
+}
+
+// Used to connect to the first address which accepts a TCP connection. The next address is tried if the previous one
+// fails or has not connected within the fallback delay, and any connections which lose the race are closed.
+func happyEyeballs(addrs []string, timeout time.Duration) (net.Conn, string, error) {
+	if len(addrs) == 0 {
+		return nil, "", errors.New("the droplet has no address we can connect to")
+	}
+	ctx, cancel := c.WithTimeout(c.Background(), timeout)
+	defer cancel()
+	type result struct {
+		conn net.Conn
+		addr string
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	dial := dialContext
+	started, pending := 0, 0
+	var fallback <-chan time.Time
+	start := func() {
+		addr := addrs[started]
+		started++
+		pending++
+		go func() {
+			conn, err := dial(ctx, "tcp", addr)
+			results <- result{conn: conn, addr: addr, err: err}
+		}()
+		fallback = nil
+		if started < len(addrs) {
+			fallback = time.After(fallbackDelay)
+		}
+	}
+	start()
+	var lastErr error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// Close any connections which finish after this one.
+				go func(n int) {
+					for i := 0; i < n; i++ {
+						if r := <-results; r.conn != nil {
+							_ = r.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, r.addr, nil
+			}
+			lastErr = r.err
+			if started < len(addrs) {
+				start()
+			}
+		case <-fallback:
+			start()
+		}
+	}
+	return nil, "", lastErr
+}
+
+// Used to connect to the droplet over SSH using whichever of its addresses connects first.
+func dialDroplet(addrs []string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, addr, err := happyEyeballs(addrs, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	// Don't let a stalled handshake hold up the setup, since the teardown waits for it.
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
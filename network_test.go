@@ -0,0 +1,159 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	c "context"
+	"github.com/digitalocean/godo"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Defines a delay which makes an address refuse the connection instead of accepting it.
+const refused = -1
+
+// Used to start a local listener for each delay. Returns the addresses and the listeners, which are closed for the
+// refused addresses so that nothing is listening on them.
+func testListeners(t *testing.T, delays []time.Duration) ([]string, []net.Listener) {
+	addrs := make([]string, len(delays))
+	listeners := make([]net.Listener, len(delays))
+	for i, d := range delays {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs[i] = ln.Addr().String()
+		listeners[i] = ln
+		if d == refused {
+			_ = ln.Close()
+		} else {
+			t.Cleanup(func() { _ = ln.Close() })
+		}
+	}
+	return addrs, listeners
+}
+
+// Used to make the addresses take a while to connect for the duration of a test. The slow dials ignore the context so
+// that they still connect after the race is lost.
+func setDialDelays(t *testing.T, addrs []string, delays []time.Duration) {
+	byAddr := map[string]time.Duration{}
+	for i, a := range addrs {
+		byAddr[a] = delays[i]
+	}
+	old := dialContext
+	dialContext = func(ctx c.Context, network, addr string) (net.Conn, error) {
+		if d := byAddr[addr]; d > 0 {
+			time.Sleep(d)
+			return net.Dial(network, addr)
+		}
+		return old(ctx, network, addr)
+	}
+	t.Cleanup(func() { dialContext = old })
+}
+
+func TestHappyEyeballs(t *testing.T) {
+	tests := []struct {
+		name       string
+		delays     []time.Duration
+		want       int
+		minElapsed time.Duration
+		maxElapsed time.Duration
+	}{
+		{"first connects", []time.Duration{0, 0}, 0, 0, fallbackDelay},
+		{"first refused", []time.Duration{refused, 0}, 1, 0, fallbackDelay},
+		{"first slow", []time.Duration{2 * time.Second, 0}, 1, fallbackDelay, 2 * time.Second},
+		{"first slower than the fallback", []time.Duration{fallbackDelay + 100*time.Millisecond, 2 * time.Second}, 0, fallbackDelay, 2 * time.Second},
+		{"all refused", []time.Duration{refused, refused}, -1, 0, fallbackDelay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addrs, _ := testListeners(t, tt.delays)
+			setDialDelays(t, addrs, tt.delays)
+			start := time.Now()
+			conn, addr, err := happyEyeballs(addrs, 5*time.Second)
+			elapsed := time.Since(start)
+			if tt.want == -1 {
+				if err == nil {
+					_ = conn.Close()
+					t.Fatal("happyEyeballs() connected when every address refuses")
+				}
+			} else if err != nil {
+				t.Fatalf("happyEyeballs() error = %v", err)
+			} else {
+				_ = conn.Close()
+				if addr != addrs[tt.want] {
+					t.Errorf("happyEyeballs() connected to %s, want %s", addr, addrs[tt.want])
+				}
+			}
+			if elapsed < tt.minElapsed || elapsed >= tt.maxElapsed {
+				t.Errorf("happyEyeballs() took %s, want between %s and %s", elapsed, tt.minElapsed, tt.maxElapsed)
+			}
+		})
+	}
+}
+
+func TestHappyEyeballsClosesLosers(t *testing.T) {
+	delays := []time.Duration{500 * time.Millisecond, 0}
+	addrs, listeners := testListeners(t, delays)
+	setDialDelays(t, addrs, delays)
+	conn, addr, err := happyEyeballs(addrs, 5*time.Second)
+	if err != nil {
+		t.Fatalf("happyEyeballs() error = %v", err)
+	}
+	defer conn.Close()
+	if addr != addrs[1] {
+		t.Fatalf("happyEyeballs() connected to %s, want %s", addr, addrs[1])
+	}
+
+	// The slow address still connects once the race is lost, and that connection should be closed.
+	loser, err := listeners[0].Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loser.Close()
+	_ = loser.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := loser.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("reading the losing connection returned %v, want EOF", err)
+	}
+}
+
+func TestDropletAddresses(t *testing.T) {
+	both := &godo.Droplet{Networks: &godo.Networks{
+		V4: []godo.NetworkV4{{IPAddress: "203.0.113.5", Type: "public"}, {IPAddress: "10.116.0.5", Type: "private"}},
+		V6: []godo.NetworkV6{{IPAddress: "2001:db8::5", Type: "public"}},
+	}}
+	v4Only := &godo.Droplet{Networks: &godo.Networks{V4: []godo.NetworkV4{{IPAddress: "203.0.113.5", Type: "public"}}}}
+	v6Only := &godo.Droplet{Networks: &godo.Networks{V6: []godo.NetworkV6{{IPAddress: "2001:db8::5", Type: "public"}}}}
+
+	tests := []struct {
+		name    string
+		droplet *godo.Droplet
+		family  int
+		want    []string
+	}{
+		{"-4", both, 4, []string{"203.0.113.5:22"}},
+		{"-6", both, 6, []string{"[2001:db8::5]:22"}},
+		{"-4 without IPv4", v6Only, 4, []string{}},
+		{"-6 without IPv6", v4Only, 6, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dropletAddresses(tt.droplet, tt.family); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dropletAddresses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
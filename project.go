@@ -0,0 +1,53 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"github.com/digitalocean/godo"
+)
+
+// Used to find a project by name. The name is matched exactly.
+func findProject(name string) (*godo.Project, error) {
+	opts := &godo.ListOptions{PerPage: 200}
+	for {
+		projects, resp, err := client.Projects.List(context(), opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range projects {
+			if v.Name == name {
+				return &v, nil
+			}
+		}
+		if resp.Links == nil || resp.Links.IsLastPage() {
+			return nil, errors.New("can't find a project named " + name)
+		}
+		current, err := resp.Links.CurrentPage()
+		if err != nil {
+			return nil, err
+		}
+		opts.Page = current + 1
+	}
+}
+
+// Used to assign resources to the project. Errors are logged rather than returned since the droplet is still usable outside of the project.
+func assignToProject(project *godo.Project, resources ...interface{}) {
+	if project == nil {
+		return
+	}
+	_, _, err := client.Projects.AssignResources(context(), project.ID, resources...)
+	if err != nil {
+		logger.Warnf("Failed to assign to the project %s: %v", project.Name, err)
+	}
+}
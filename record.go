@@ -0,0 +1,124 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Defines the header of an asciicast v2 recording.
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Used to record the session output in the asciicast v2 format. All methods are safe to call on a nil recorder.
+type recorder struct {
+	mu      sync.Mutex
+	f       *os.File
+	enc     *json.Encoder
+	start   time.Time
+	partial []byte
+}
+
+// Used to create the recording file. This is done before the droplet is created so that a bad path fails early.
+func newRecorder(path string) (*recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &recorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Used to write the header and start the clock for the recording.
+func (r *recorder) begin(title string, w, h int) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.start = time.Now()
+	return r.enc.Encode(&asciicastHeader{
+		Version:   2,
+		Width:     w,
+		Height:    h,
+		Timestamp: r.start.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": terminalType()},
+	})
+}
+
+// Used to write an event. The lock must be held.
+func (r *recorder) event(kind, data string) {
+	if r.start.IsZero() {
+		return
+	}
+	_ = r.enc.Encode([]interface{}{time.Since(r.start).Seconds(), kind, data})
+}
+
+// Used to record output. Characters split across writes are held back until they are complete so the JSON stays valid UTF-8.
+func (r *recorder) Write(b []byte) (int, error) {
+	if r == nil {
+		return len(b), nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := append(r.partial, b...)
+	end := len(data)
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				end = len(data) - i
+			}
+			break
+		}
+	}
+	r.partial = append([]byte(nil), data[end:]...)
+	if end != 0 {
+		r.event("o", string(data[:end]))
+	}
+	return len(b), nil
+}
+
+// Used to record the terminal being resized.
+func (r *recorder) resize(w, h int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.event("r", strconv.Itoa(w)+"x"+strconv.Itoa(h))
+}
+
+// Used to close the recording.
+func (r *recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.partial) != 0 {
+		r.event("o", string(r.partial))
+		r.partial = nil
+	}
+	return r.f.Close()
+}
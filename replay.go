@@ -0,0 +1,165 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	c "context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/google/subcommands"
+	"os"
+	"time"
+)
+
+type replayCmd struct {
+	speed float64
+	idleLimit time.Duration
+}
+
+func (*replayCmd) Name() string     { return "replay" }
+func (*replayCmd) Synopsis() string { return "Allows you to replay a session recorded with up -record." }
+func (*replayCmd) Usage() string {
+	return `replay [-speed <multiplier>] [-idle-limit <duration>] <file>:
+  Allows you to replay a session recorded with up -record. While replaying, space pauses/resumes, + and - double/halve the speed, . steps to the next output when paused and q quits.
+`
+}
+
+func (p *replayCmd) SetFlags(f *flag.FlagSet) {
+	f.Float64Var(&p.speed, "speed", 1, "Sets the speed multiplier of the playback.")
+	f.DurationVar(&p.idleLimit, "idle-limit", 0, "Sets the maximum time to wait between output (e.g. 2s). Longer pauses are shortened to this.")
+}
+
+// Defines the playback controls of a replay.
+type replayControls struct {
+	keys   <-chan []byte
+	speed  float64
+	paused bool
+}
+
+// Used to wait for the given amount of recording time, handling any key presses while waiting. Returns false if the playback should stop.
+func (r *replayControls) wait(d float64) bool {
+	for {
+		var timer <-chan time.Time
+		if !r.paused {
+			if d <= 0 {
+				return true
+			}
+			timer = time.After(time.Duration(d / r.speed * float64(time.Second)))
+		}
+		start := time.Now()
+		select {
+		case <-timer:
+			return true
+		case b, ok := <-r.keys:
+			if !ok {
+				r.keys = nil
+				continue
+			}
+			if !r.paused {
+				d -= time.Since(start).Seconds() * r.speed
+			}
+			for _, k := range b {
+				switch k {
+				case ' ':
+					r.paused = !r.paused
+				case '+', '=':
+					r.speed *= 2
+				case '-':
+					r.speed /= 2
+				case '.':
+					if r.paused {
+						return true
+					}
+				case 'q', '\x03':
+					return false
+				}
+			}
+		}
+	}
+}
+
+// Used to replay the recording.
+func (p *replayCmd) replay(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	// Read the header.
+	if !scanner.Scan() {
+		return errors.New("the recording is empty")
+	}
+	var header asciicastHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Version != 2 {
+		return errors.New("the recording is not an asciicast v2 file")
+	}
+	if w, h := terminalSize(); w < header.Width || h < header.Height {
+		fmt.Fprintf(os.Stderr, "The recording is %dx%d but your terminal is %dx%d, so it may not display correctly.\n", header.Width, header.Height, w, h)
+	}
+
+	// Put the terminal in raw mode so that the controls work without pressing enter.
+	controls := &replayControls{speed: p.speed}
+	term, err := makeRaw()
+	if err != nil {
+		return err
+	}
+	defer term.restore()
+	if term != nil {
+		controls.keys = stdinReader()
+	}
+
+	// Play each event.
+	last := 0.0
+	for scanner.Scan() {
+		var ev []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil || len(ev) < 3 {
+			return errors.New("the recording has an invalid event")
+		}
+		t, _ := ev[0].(float64)
+		kind, _ := ev[1].(string)
+		data, _ := ev[2].(string)
+		delay := t - last
+		last = t
+		if p.idleLimit > 0 && delay > p.idleLimit.Seconds() {
+			delay = p.idleLimit.Seconds()
+		}
+		if !controls.wait(delay) {
+			break
+		}
+		if kind == "o" {
+			_, _ = os.Stdout.WriteString(data)
+		}
+	}
+	term.restore()
+	println()
+	return scanner.Err()
+}
+
+func (p *replayCmd) Execute(_ c.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 || p.speed <= 0 {
+		println(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	err := p.replay(f.Arg(0))
+	if err != nil {
+		println(err.Error())
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,36 @@
+// +build !windows
+
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Used to call resize with the new terminal size whenever the window is resized until done is closed.
+func watchWindowSize(done <-chan struct{}, resize func(w, h int)) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGWINCH)
+	defer signal.Stop(c)
+	for {
+		select {
+		case <-c:
+			resize(terminalSize())
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import "time"
+
+// Used to call resize with the new terminal size whenever the window is resized until done is closed.
+// Windows has no SIGWINCH, so the size is polled instead.
+func watchWindowSize(done <-chan struct{}, resize func(w, h int)) {
+	currentWidth, currentHeight := terminalSize()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w, h := terminalSize()
+			if w != currentWidth || h != currentHeight {
+				currentWidth, currentHeight = w, h
+				resize(w, h)
+			}
+		case <-done:
+			return
+		}
+	}
+}
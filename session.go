@@ -0,0 +1,95 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"golang.org/x/crypto/ssh"
+	"io"
+	"os"
+)
+
+// Used to pass a signal which was received through the error channel.
+type signalError struct {
+	sig os.Signal
+}
+
+func (*signalError) Error() string { return "" }
+
+// Used to forward a signal to the droplet session. With a PTY, the key which the terminal would send for the signal is
+// written so that the droplet handles it like a key press. Otherwise, a SSH signal request is sent.
+func forwardSignal(session *ssh.Session, stdin io.Writer, pty bool, sig os.Signal) error {
+	name, key := sshSignal(sig)
+	if pty && key != "" {
+		_, err := io.WriteString(stdin, key)
+		return err
+	}
+	if name == "" {
+		// There is no SSH signal for this.
+		return nil
+	}
+	return session.Signal(name)
+}
+
+// Defines the help shown for the escape sequences.
+const escapeHelp = "Supported escape sequences:\r\n" +
+	" ~.  - end the session and destroy the droplet\r\n" +
+	" ~?  - this message\r\n" +
+	" ~~  - send the escape character by typing it twice\r\n" +
+	"(Note that escapes are only recognized immediately after newline.)\r\n"
+
+// Defines the escape sequence help shown in the extra sessions opened with do-disposable shell.
+const shellEscapeHelp = "Supported escape sequences:\r\n" +
+	" ~.  - close this shell (the droplet keeps running)\r\n" +
+	" ~?  - this message\r\n" +
+	" ~~  - send the escape character by typing it twice\r\n" +
+	"(Note that escapes are only recognized immediately after newline.)\r\n"
+
+// Used to handle OpenSSH style escape sequences in the input. Like OpenSSH, these are only recognised at the start of a line.
+// The help defaults to escapeHelp.
+type escapeScanner struct {
+	midLine      bool
+	pendingTilde bool
+	help         string
+}
+
+// Used to filter a chunk of input. Returns the bytes to send to the droplet and if the session should be ended.
+func (e *escapeScanner) scan(b []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(b))
+	for _, c := range b {
+		if e.pendingTilde {
+			e.pendingTilde = false
+			switch c {
+			case '.':
+				return out, true
+			case '?':
+				if e.help == "" {
+					print(escapeHelp)
+				} else {
+					print(e.help)
+				}
+				continue
+			case '~':
+				out = append(out, '~')
+			default:
+				out = append(out, '~', c)
+			}
+		} else if !e.midLine && c == '~' {
+			e.pendingTilde = true
+			continue
+		} else {
+			out = append(out, c)
+		}
+		e.midLine = c != '\r' && c != '\n'
+	}
+	return out, false
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	c "context"
+	"flag"
+	"github.com/google/subcommands"
+	"strings"
+)
+
+type setDefaultsCmd struct {
+	vpc string
+	tags string
+	monitoring bool
+	firewall bool
+	project string
+}
+
+func (*setDefaultsCmd) Name() string     { return "setdefaults" }
+func (*setDefaultsCmd) Synopsis() string { return "Allows you to set the default VPC, tags, project, monitoring and firewall options used by up." }
+func (*setDefaultsCmd) Usage() string {
+	return `setdefaults [-vpc <uuid>] [-tags <tag,tag>] [-project <name>] [-monitoring=true|false] [-firewall=true|false]:
+  Allows you to set the default VPC, tags, project, monitoring and firewall options used by up. Only the flags which are given are changed.
+`
+}
+
+func (p *setDefaultsCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.vpc, "vpc", "", "Sets the UUID of the VPC droplets are created in. Set to an empty string to use the default VPC of the region.")
+	f.StringVar(&p.tags, "tags", "", "Sets a comma separated list of extra tags added to droplets.")
+	f.StringVar(&p.project, "project", "", "Sets the name of the project droplets are assigned to. Set to an empty string to use the default project of the account.")
+	f.BoolVar(&p.monitoring, "monitoring", false, "Sets if the monitoring agent is installed on droplets.")
+	f.BoolVar(&p.firewall, "firewall", true, "Sets if a cloud firewall only allowing SSH from your IP is created for droplets.")
+}
+
+func (p *setDefaultsCmd) Execute(_ c.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	fp, err := clientInit()
+	if err != nil {
+		return exitStatus(err)
+	}
+	f.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "vpc":
+			config.DefaultVPC = p.vpc
+		case "tags":
+			config.DefaultTags = splitTags(p.tags)
+		case "project":
+			config.DefaultProject = p.project
+		case "monitoring":
+			config.Monitoring = p.monitoring
+		case "firewall":
+			config.NoFirewall = !p.firewall
+		}
+	})
+	if err := writeConfig(fp); err != nil {
+		return exitStatus(err)
+	}
+	println("VPC: " + config.DefaultVPC)
+	println("Tags: " + strings.Join(config.DefaultTags, ","))
+	println("Project: " + config.DefaultProject)
+	if config.Monitoring {
+		println("Monitoring: enabled")
+	} else {
+		println("Monitoring: disabled")
+	}
+	if config.NoFirewall {
+		println("Firewall: disabled")
+	} else {
+		println("Firewall: enabled")
+	}
+	return subcommands.ExitSuccess
+}
+
+// Used to split a comma separated list of tags.
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			tags = append(tags, v)
+		}
+	}
+	return tags
+}
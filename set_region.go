@@ -31,7 +31,9 @@ func (*setRegionCmd) Usage() string {
 func (p *setRegionCmd) SetFlags(_ *flag.FlagSet) {}
 
 func (p *setRegionCmd) Execute(_ c.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	clientInit()
-	setDefaultRegion()
-	return subcommands.ExitSuccess
+	if _, err := clientInit(); err != nil {
+		return exitStatus(err)
+	}
+	_, err := setDefaultRegion()
+	return exitStatus(err)
 }
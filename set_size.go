@@ -31,7 +31,8 @@ func (*setSizeCmd) Usage() string {
 func (p *setSizeCmd) SetFlags(_ *flag.FlagSet) {}
 
 func (p *setSizeCmd) Execute(_ c.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	clientInit()
-	setSize(nil)
-	return subcommands.ExitSuccess
+	if _, err := clientInit(); err != nil {
+		return exitStatus(err)
+	}
+	return exitStatus(setSize(nil))
 }
@@ -0,0 +1,139 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	c "context"
+	"flag"
+	"github.com/google/subcommands"
+	"os"
+	"sort"
+	"strings"
+)
+
+type setSyncCmd struct {
+	template string
+	deleteTemplate bool
+	files stringSliceFlag
+	removeFiles stringSliceFlag
+	env stringSliceFlag
+	removeEnv stringSliceFlag
+}
+
+func (*setSyncCmd) Name() string     { return "setsync" }
+func (*setSyncCmd) Synopsis() string { return "Allows you to set the dotfiles and environment variables pushed to the droplet before the shell opens." }
+func (*setSyncCmd) Usage() string {
+	return `setsync [-template <name>] [-file <path>]... [-remove-file <path>]... [-env <name>]... [-remove-env <name>]... [-delete-template]:
+  Allows you to set the dotfiles and environment variables pushed to the droplet before the shell opens. Templates can override the files and environment variables and are picked with up -template. The resulting config is printed.
+`
+}
+
+func (p *setSyncCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.template, "template", "", "Sets the name of the template to change instead of the defaults.")
+	f.BoolVar(&p.deleteTemplate, "delete-template", false, "Deletes the template.")
+	f.Var(&p.files, "file", "Adds a file or folder to push. This is relative to your home directory (e.g. .bashrc). Can be specified multiple times.")
+	f.Var(&p.removeFiles, "remove-file", "Removes a file or folder. Can be specified multiple times.")
+	f.Var(&p.env, "env", "Adds the name of a local environment variable to push. Can be specified multiple times.")
+	f.Var(&p.removeEnv, "remove-env", "Removes an environment variable. Can be specified multiple times.")
+}
+
+// Used to add and remove items from a list while keeping the order.
+func updateList(list, add, remove []string) []string {
+	removed := map[string]bool{}
+	for _, v := range remove {
+		removed[v] = true
+	}
+	result := make([]string, 0, len(list)+len(add))
+	seen := map[string]bool{}
+	for _, v := range append(list, add...) {
+		if removed[v] || seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	return result
+}
+
+// Used to print a sync config.
+func printSyncConfig(name string, s *syncConfig) {
+	println(name + ":")
+	println("  Files: " + strings.Join(s.Files, ", "))
+	println("  Environment variables: " + strings.Join(s.Env, ", "))
+}
+
+func (p *setSyncCmd) Execute(_ c.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	fp, err := clientInit()
+	if err != nil {
+		return exitStatus(err)
+	}
+
+	// Validate what is being added.
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return exitStatus(err)
+	}
+	for i, v := range p.files {
+		rel, err := syncPath(homedir, v)
+		if err != nil {
+			println(err.Error())
+			return subcommands.ExitUsageError
+		}
+		p.files[i] = rel
+	}
+	for _, v := range p.env {
+		if !validEnvName.MatchString(v) {
+			println(v + " is not a valid environment variable name.")
+			return subcommands.ExitUsageError
+		}
+	}
+
+	// Get the config to change.
+	s := &config.Sync
+	if p.template != "" {
+		if p.deleteTemplate {
+			delete(config.SyncTemplates, p.template)
+			s = nil
+		} else {
+			if config.SyncTemplates == nil {
+				config.SyncTemplates = map[string]*syncConfig{}
+			}
+			if config.SyncTemplates[p.template] == nil {
+				config.SyncTemplates[p.template] = &syncConfig{}
+			}
+			s = config.SyncTemplates[p.template]
+		}
+	} else if p.deleteTemplate {
+		println("-delete-template needs -template.")
+		return subcommands.ExitUsageError
+	}
+	if s != nil {
+		s.Files = updateList(s.Files, p.files, p.removeFiles)
+		s.Env = updateList(s.Env, p.env, p.removeEnv)
+	}
+	if err := writeConfig(fp); err != nil {
+		return exitStatus(err)
+	}
+
+	// Print the result.
+	printSyncConfig("Defaults", &config.Sync)
+	names := make([]string, 0, len(config.SyncTemplates))
+	for k := range config.SyncTemplates {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, v := range names {
+		printSyncConfig("Template "+v, config.SyncTemplates[v])
+	}
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	c "context"
+	"encoding/gob"
+	"flag"
+	"github.com/google/subcommands"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+type shellCmd struct {}
+
+func (*shellCmd) Name() string     { return "shell" }
+func (*shellCmd) Synopsis() string { return "Allows you to open another shell on a droplet started with up." }
+func (*shellCmd) Usage() string {
+	return `shell [droplet name]:
+  Allows you to open another shell on a droplet started with up. The shell uses the SSH connection of the up process, so this has to be ran on the same machine. The droplet name is only needed if more than one droplet is running.
+`
+}
+
+func (p *shellCmd) SetFlags(_ *flag.FlagSet) {}
+
+// Used to find the control socket of the droplet.
+func findControlSocket(name string) (string, bool) {
+	droplets, err := runningDroplets()
+	if err != nil {
+		println(err.Error())
+		return "", false
+	}
+	if name != "" {
+		path, ok := droplets[name]
+		if !ok {
+			println("There is no running droplet named " + name + ".")
+		}
+		return path, ok
+	}
+	switch len(droplets) {
+	case 0:
+		println("There are no running droplets. Start one with do-disposable up.")
+		return "", false
+	case 1:
+		for _, v := range droplets {
+			return v, true
+		}
+	}
+	names := make([]string, 0, len(droplets))
+	for k := range droplets {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	println("There is more than one running droplet. Please give the name of one of them: " + strings.Join(names, ", "))
+	return "", false
+}
+
+func (p *shellCmd) Execute(_ c.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() > 1 {
+		println(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	path, ok := findControlSocket(f.Arg(0))
+	if !ok {
+		return subcommands.ExitFailure
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		println(err.Error())
+		return subcommands.ExitFailure
+	}
+	defer conn.Close()
+	out := &controlWriter{enc: gob.NewEncoder(conn)}
+	dec := gob.NewDecoder(conn)
+
+	// Ask for the shell.
+	width, height := terminalSize()
+	err = out.enc.Encode(&shellRequest{Term: terminalType(), Width: width, Height: height})
+	if err != nil {
+		println(err.Error())
+		return subcommands.ExitFailure
+	}
+
+	// Put the terminal in raw mode.
+	term, err := makeRaw()
+	if err != nil {
+		println(err.Error())
+		return subcommands.ExitFailure
+	}
+	defer term.restore()
+
+	// Handle input, resizes and signals.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		escapes := escapeScanner{help: shellEscapeHelp}
+		for b := range stdinReader() {
+			if term != nil {
+				var end bool
+				b, end = escapes.scan(b)
+				if end {
+					_ = conn.Close()
+					return
+				}
+			}
+			if out.send(&controlMessage{Data: b}) != nil {
+				return
+			}
+		}
+	}()
+	go watchWindowSize(done, func(w, h int) {
+		_ = out.send(&controlMessage{Width: w, Height: h})
+	})
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, append([]os.Signal{os.Interrupt, syscall.SIGTERM}, sessionSignals...)...)
+	go func() {
+		for sig := range sigs {
+			name, key := sshSignal(sig)
+			if key != "" {
+				_ = out.send(&controlMessage{Data: []byte(key)})
+			} else if name != "" {
+				_ = out.send(&controlMessage{Signal: string(name)})
+			}
+		}
+	}()
+
+	// Write the output until the session ends.
+	for {
+		var m controlMessage
+		if err := dec.Decode(&m); err != nil {
+			term.restore()
+			println("\nThe connection to the droplet was closed.")
+			return subcommands.ExitFailure
+		}
+		if m.Exit {
+			return subcommands.ExitStatus(m.ExitStatus)
+		}
+		_, _ = os.Stdout.Write(m.Data)
+	}
+}
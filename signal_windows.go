@@ -0,0 +1,33 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"golang.org/x/crypto/ssh"
+	"os"
+	"syscall"
+)
+
+// Defines the signals which are forwarded to the droplet session on top of SIGINT and SIGTERM.
+var sessionSignals []os.Signal
+
+// Used to get the SSH signal name and the key which a terminal sends for a signal.
+func sshSignal(sig os.Signal) (ssh.Signal, string) {
+	switch sig {
+	case os.Interrupt:
+		return ssh.SIGINT, "\x03"
+	case syscall.SIGTERM:
+		return ssh.SIGTERM, ""
+	}
+	return "", ""
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"github.com/digitalocean/godo"
+	"strconv"
+	"time"
+)
+
+// Used to get the default name of a snapshot taken on exit.
+func defaultSnapshotName() string {
+	return "do-disposable-" + time.Now().Format("2006-01-02-150405")
+}
+
+// Used to power off the droplet and take a snapshot of it. Returns the ID of the snapshot image.
+func snapshotDroplet(dropletID int, name string) (int, error) {
+	print("Powering off the droplet... ")
+	a, _, err := client.DropletActions.PowerOff(context(), dropletID)
+	if err != nil {
+		return 0, err
+	}
+	err = waitForDropletAction(dropletID, a.ID)
+	if err != nil {
+		return 0, err
+	}
+	println("done!")
+
+	print("Taking the snapshot " + name + " (this may take a while)... ")
+	a, _, err = client.DropletActions.Snapshot(context(), dropletID, name)
+	if err != nil {
+		return 0, err
+	}
+	err = waitForDropletAction(dropletID, a.ID)
+	if err != nil {
+		return 0, err
+	}
+	println("done!")
+
+	snapshots, _, err := client.Droplets.Snapshots(context(), dropletID, &godo.ListOptions{PerPage: 200})
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range snapshots {
+		if v.Name == name {
+			return v.ID, nil
+		}
+	}
+	return 0, errors.New("the snapshot " + name + " was not found after it was taken")
+}
+
+// Used to handle snapshotting the droplet on exit. If prompt is true, the user is asked for the snapshot name.
+// Returns an error if the droplet should not be destroyed because the snapshot failed.
+func handleSnapshotOnExit(dropletID int, prompt bool) error {
+	name := defaultSnapshotName()
+	if prompt {
+		text := GetInput("Do you want to snapshot the droplet before it is destroyed? Enter a snapshot name (or \"y\" for " + name + "), or leave this blank to skip: ")
+		if text == "" {
+			return nil
+		}
+		if text != "y" && text != "Y" {
+			name = text
+		}
+	}
+	id, err := snapshotDroplet(dropletID, name)
+	if err != nil {
+		return snapshotError(dropletID, err)
+	}
+	println("Created the snapshot " + name + " with the ID " + strconv.Itoa(id) + ". You can start a new droplet from it with do-disposable up -snapshot " + shellQuote(name) + " or -image " + strconv.Itoa(id) + ".")
+	return nil
+}
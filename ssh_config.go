@@ -0,0 +1,145 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	c "context"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"github.com/google/subcommands"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+type sshConfigCmd struct {}
+
+func (*sshConfigCmd) Name() string     { return "ssh-config" }
+func (*sshConfigCmd) Synopsis() string { return "Allows you to get an OpenSSH config block for a droplet started with up." }
+func (*sshConfigCmd) Usage() string {
+	return `ssh-config [droplet name]:
+  Allows you to get an OpenSSH config block for a droplet started with up. This lets ssh, scp, VS Code Remote and Ansible connect to the droplet while the session is running. The droplet name is only needed if more than one droplet is running.
+`
+}
+
+func (p *sshConfigCmd) SetFlags(_ *flag.FlagSet) {}
+
+func (p *sshConfigCmd) Execute(_ c.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() > 1 {
+		println(p.Usage())
+		return subcommands.ExitUsageError
+	}
+	path, ok := findControlSocket(f.Arg(0))
+	if !ok {
+		return subcommands.ExitFailure
+	}
+	b, err := ioutil.ReadFile(strings.TrimSuffix(path, ".sock") + ".ssh_config")
+	if err != nil {
+		println(err.Error())
+		return subcommands.ExitFailure
+	}
+	fmt.Print(string(b))
+	return subcommands.ExitSuccess
+}
+
+// Used to write the private key to a file which OpenSSH can use. Returns the path of the file.
+func writeIdentityFile() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	fp := filepath.Join(homedir, ".do-disposable-key")
+	b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(config.PrivateKey)})
+	err = ioutil.WriteFile(fp, b, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	// OpenSSH refuses keys which other users can read, so make sure an existing file is locked down too.
+	return fp, os.Chmod(fp, 0600)
+}
+
+// Used to write the OpenSSH config and known hosts files for the droplet. The host key is pinned to the one we saw when connecting.
+// Returns the path of the config file.
+func writeSSHConfig(name, user string, addr net.Addr, hostKey ssh.PublicKey) (string, error) {
+	dir, err := controlSocketDir()
+	if err != nil {
+		return "", err
+	}
+	identityFile, err := writeIdentityFile()
+	if err != nil {
+		return "", err
+	}
+	knownHostsFile := filepath.Join(dir, name+".known_hosts")
+	err = ioutil.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{name}, hostKey)+"\n"), 0600)
+	if err != nil {
+		return "", err
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return "", err
+	}
+	block := "Host " + name + "\n" +
+		"    HostName " + host + "\n" +
+		"    Port " + port + "\n" +
+		"    User " + user + "\n" +
+		"    IdentityFile \"" + identityFile + "\"\n" +
+		"    IdentitiesOnly yes\n" +
+		"    HostKeyAlias " + name + "\n" +
+		"    UserKnownHostsFile \"" + knownHostsFile + "\"\n" +
+		"    StrictHostKeyChecking yes\n"
+	configFile := filepath.Join(dir, name+".ssh_config")
+	return configFile, ioutil.WriteFile(configFile, []byte(block), 0600)
+}
+
+// Used to remove the OpenSSH files for the droplet once it is destroyed.
+func removeSSHConfig(name string) {
+	dir, err := controlSocketDir()
+	if err != nil {
+		return
+	}
+	_ = os.Remove(filepath.Join(dir, name+".known_hosts"))
+	_ = os.Remove(filepath.Join(dir, name+".ssh_config"))
+}
+
+// Used to run the system ssh binary against the droplet instead of the built-in client. ssh is terminated when stop is closed.
+func execSSH(configFile, name string, stop <-chan struct{}) error {
+	cmd := exec.Command("ssh", "-F", configFile, name)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-stop:
+			// SIGTERM lets ssh restore the terminal. Windows can't send it, so kill it there.
+			if cmd.Process.Signal(syscall.SIGTERM) != nil {
+				_ = cmd.Process.Kill()
+			}
+		case <-exited:
+		}
+	}()
+	return cmd.Wait()
+}
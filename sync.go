@@ -0,0 +1,173 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Defines the environment variable names which can be exported by a POSIX shell.
+var validEnvName = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// Defines the dotfiles/folders and environment variables which are pushed to the droplet before the shell opens.
+// Files are relative to the home directory and are pushed to the same path relative to the droplet home directory.
+type syncConfig struct {
+	Files []string
+	Env   []string
+}
+
+// Used to get the sync config for a template. The lists which the template sets override the defaults.
+func getSyncConfig(template string) (*syncConfig, error) {
+	s := config.Sync
+	if template == "" {
+		return &s, nil
+	}
+	t, ok := config.SyncTemplates[template]
+	if !ok {
+		return nil, errors.New("there is no sync template named " + template)
+	}
+	if len(t.Files) != 0 {
+		s.Files = t.Files
+	}
+	if len(t.Env) != 0 {
+		s.Env = t.Env
+	}
+	return &s, nil
+}
+
+// Used to get a file to sync relative to the home directory. Files outside of the home directory are rejected since
+// there is nowhere obvious to put them on the droplet.
+func syncPath(homedir, p string) (string, error) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(homedir, p)
+	}
+	rel, err := filepath.Rel(homedir, filepath.Clean(p))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New(p + " is not within your home directory")
+	}
+	return rel, nil
+}
+
+// Used to write shell exports to a file in the droplet home directory and source it from the shell profiles. Returns the path of the file on the droplet.
+func writeRemoteEnv(client *ssh.Client, file string, env map[string]string) (string, error) {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		if !validEnvName.MatchString(k) {
+			return "", errors.New(k + " is not a valid environment variable name")
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString("export " + k + "=" + shellQuote(env[k]) + "\n")
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	session.Stdin = strings.NewReader(b.String())
+	err = session.Run("umask 077 && cat > " + file)
+	if err != nil {
+		return "", err
+	}
+
+	// Bash only reads .bash_profile for login shells if it exists, and .bashrc for the rest.
+	line := shellQuote("[ -f ~/" + file + " ] && . ~/" + file)
+	err = runRemote(client, "for f in .profile .bash_profile .bashrc; do "+
+		"if [ $f = .profile ] || [ -f $f ]; then grep -qxF "+line+" $f 2>/dev/null || echo "+line+" >> $f; fi; done", nil)
+	if err != nil {
+		return "", err
+	}
+	home, err := remoteOutput(client, "echo $HOME")
+	if err != nil {
+		return "", err
+	}
+	return home + "/" + file, nil
+}
+
+// Used to push the dotfiles/folders and environment variables to the droplet.
+// Returns the path of the file the environment variables were written to, which is removed in the teardown since it holds local values.
+func syncToDroplet(client *ssh.Client, s *syncConfig) (string, error) {
+	if len(s.Files) == 0 && len(s.Env) == 0 {
+		return "", nil
+	}
+	print("Syncing dotfiles and environment variables... ")
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	files := make([]*watchSpec, 0, len(s.Files))
+	for _, v := range s.Files {
+		rel, err := syncPath(homedir, v)
+		if err != nil {
+			return "", err
+		}
+
+		// Follow links so that dotfiles which are managed with tools such as stow are pushed.
+		p, err := filepath.EvalSymlinks(filepath.Join(homedir, rel))
+		if os.IsNotExist(err) {
+			// This dotfile isn't on this machine.
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		files = append(files, &watchSpec{LocalDir: p, RemoteDir: filepath.ToSlash(rel)})
+	}
+	if len(files) != 0 {
+		// Stream all of the dotfiles in one session. Images without tar get one session per file instead.
+		err = pushTar(client, ".", func(tw *tar.Writer) error {
+			for _, f := range files {
+				if err := addToTar(tw, f.LocalDir, f.RemoteDir); err != nil {
+					return errors.New("failed to push " + f.LocalDir + ": " + err.Error())
+				}
+			}
+			return nil
+		})
+		if err == errNoRemoteTar {
+			err = nil
+			for _, f := range files {
+				if err = f.push(client, f.LocalDir); err != nil {
+					err = errors.New("failed to push " + f.LocalDir + ": " + err.Error())
+					break
+				}
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	env := map[string]string{}
+	for _, k := range s.Env {
+		if v, ok := os.LookupEnv(k); ok {
+			env[k] = v
+		}
+	}
+	envPath := ""
+	if len(env) != 0 {
+		envPath, err = writeRemoteEnv(client, ".do-disposable-env", env)
+		if err != nil {
+			return "", err
+		}
+	}
+	println("done!")
+	return envPath, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"golang.org/x/crypto/ssh/terminal"
+	"os"
+	"sync"
+)
+
+// Used to handle the local terminal while the droplet session is running.
+type localTerminal struct {
+	state *terminal.State
+	once  sync.Once
+}
+
+// Used to put the local terminal in raw mode so that the droplet gets every key press as it is typed.
+// Returns nil if stdin is not a terminal.
+func makeRaw() (*localTerminal, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, nil
+	}
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	logger.setRaw(true)
+	return &localTerminal{state: state}, nil
+}
+
+// Used to restore the local terminal. This is safe to call more than once and on a nil terminal.
+func (t *localTerminal) restore() {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() {
+		_ = terminal.Restore(int(os.Stdin.Fd()), t.state)
+		logger.setRaw(false)
+	})
+}
+
+// Used to get the type of the local terminal to pass through to the droplet.
+func terminalType() string {
+	if t := os.Getenv("TERM"); t != "" {
+		return t
+	}
+	return "xterm"
+}
+
+// Used to get the width and height of the local terminal. Defaults to 80x24 if this can't be found.
+func terminalSize() (int, int) {
+	w, h, err := terminal.GetSize(int(os.Stdout.Fd()))
+	if err != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
@@ -14,16 +14,45 @@ package main
 
 import (
 	c "context"
+	"errors"
 	"flag"
 	"github.com/digitalocean/godo"
 	"github.com/google/subcommands"
+	"os/exec"
+	"sort"
+	"strings"
 	"time"
 )
 
 type upCmd struct {
 	distro string
+	distroFamily string
+	image string
+	snapshot string
+	app string
+	chooseImage bool
+	snapshotOnExit bool
+	volume string
+	newVolume int64
+	deleteVolume bool
+	volumeMount string
 	region string
 	slug string
+	watch stringSliceFlag
+	vpc string
+	tags stringSliceFlag
+	monitoring bool
+	firewall bool
+	project string
+	ipv4 bool
+	ipv6 bool
+	private bool
+	record string
+	execSSH bool
+	user bool
+	template string
+	env stringSliceFlag
+	envFiles stringSliceFlag
 }
 
 func (*upCmd) Name() string     { return "up" }
@@ -35,55 +64,254 @@ func (*upCmd) Usage() string {
 }
 
 func (p *upCmd) SetFlags(f *flag.FlagSet) {
-	f.StringVar(&p.distro, "distro", "", "Sets the distro slug. Will default to the newest Debian release.")
+	f.StringVar(&p.distro, "distro", "", "Sets the distro slug. Will default to the newest release of the distro family.")
+	f.StringVar(&p.distroFamily, "distro-family", "Debian", "Sets the distribution which the newest release is picked from when no distro slug is given (e.g. Ubuntu, Fedora, Rocky Linux, Alpine).")
+	f.StringVar(&p.image, "image", "", "Sets the ID or slug of the image to start the droplet from.")
+	f.StringVar(&p.snapshot, "snapshot", "", "Sets the name of one of your snapshots to start the droplet from.")
+	f.StringVar(&p.app, "app", "", "Sets the slug of the marketplace app to start the droplet from.")
+	f.BoolVar(&p.chooseImage, "choose-image", false, "Lets you choose one of your snapshots or custom images to start the droplet from.")
 	f.StringVar(&p.region, "region", "", "Sets the region. Will default to the default region within the config.")
 	f.StringVar(&p.slug, "size", "", "Sets the size slug of the droplet you want. Will default to the default size slug within the config.")
+	f.BoolVar(&p.snapshotOnExit, "snapshot-on-exit", false, "Snapshots the droplet before it is destroyed without prompting. Otherwise you will be asked if you want a snapshot when you exit.")
+	f.StringVar(&p.volume, "volume", "", "Sets the name of an existing block storage volume to attach to the droplet.")
+	f.Int64Var(&p.newVolume, "new-volume", 0, "Creates a new block storage volume of this size (in GiB) and attaches it to the droplet.")
+	f.BoolVar(&p.deleteVolume, "delete-volume", false, "Deletes the volume created with -new-volume when the droplet is destroyed.")
+	f.StringVar(&p.volumeMount, "volume-mount", "", "Sets the path which the volume is mounted at. Will default to /mnt/<volume name>.")
+	f.Var(&p.watch, "watch", "Watches a host directory and pushes changed files to the droplet as they are saved. Takes the format <dir>[:<remote dir>] and can be specified multiple times.")
+	f.StringVar(&p.vpc, "vpc", "", "Sets the UUID of the VPC to create the droplet in. Will default to the default VPC within the config.")
+	f.Var(&p.tags, "tag", "Adds an extra tag to the droplet. Can be specified multiple times and is added to the default tags within the config.")
+	f.BoolVar(&p.monitoring, "monitoring", false, "Installs the monitoring agent on the droplet. Will default to the monitoring option within the config.")
+	f.StringVar(&p.project, "project", "", "Sets the name of the project to assign the droplet and any volumes it creates to. Will default to the default project within the config.")
+	f.BoolVar(&p.ipv4, "4", false, "Forces the connection to the droplet to use IPv4.")
+	f.BoolVar(&p.ipv6, "6", false, "Forces the connection to the droplet to use IPv6.")
+	f.BoolVar(&p.private, "private", false, "Connects to the droplet over its private network address. This is done automatically when running on a droplet in the same VPC.")
+	f.StringVar(&p.record, "record", "", "Records the session to this file in the asciicast v2 format. This can be played back with replay.")
+	f.BoolVar(&p.execSSH, "exec-ssh", false, "Uses the system ssh binary for the session instead of the built-in client.")
+	f.BoolVar(&p.user, "user", false, "Creates a user matching your local username with passwordless sudo and uses it for the session instead of root.")
+	f.StringVar(&p.template, "template", "", "Sets the sync template which overrides the dotfiles and environment variables pushed to the droplet.")
+	f.Var(&p.env, "env", "Passes an environment variable to the remote shell. Takes the format NAME (to use the local value) or NAME=value and can be specified multiple times.")
+	f.Var(&p.envFiles, "env-file", "Passes the environment variables in a file of NAME=value lines to the remote shell. Can be specified multiple times.")
+	f.BoolVar(&p.firewall, "firewall", true, "Creates a cloud firewall which only allows SSH from your IP. Will default to the firewall option within the config.")
 }
 
-func getLatestDebian(distros []godo.Image) string {
-	debian := make([]godo.Image, 0, 1)
+// Used to get the slug of the newest image of a distribution. The family is matched case insensitively.
+func getLatestDistro(distros []godo.Image, family string) (string, error) {
+	matching := make([]godo.Image, 0, 1)
+	families := map[string]bool{}
 	for _, v := range distros {
-		if v.Distribution == "Debian" {
-			debian = append(debian, v)
+		families[v.Distribution] = true
+		if strings.EqualFold(v.Distribution, family) && v.Slug != "" {
+			matching = append(matching, v)
 		}
 	}
-	if len(debian) == 0 {
-		panic("can't find latest debian x64")
+	if len(matching) == 0 {
+		available := make([]string, 0, len(families))
+		for k := range families {
+			available = append(available, k)
+		}
+		sort.Strings(available)
+		return "", errors.New("can't find a release of " + family + ". The available distro families are: " + strings.Join(available, ", "))
 	}
 	var latest godo.Image
 	var latestTime time.Time
-	for _, v := range debian {
+	for _, v := range matching {
 		t, err := time.ParseInLocation("2006-01-02T15:04:05Z", v.Created, time.UTC)
 		if err != nil {
-			panic(err)
+			return "", err
 		}
 		if latestTime.Before(t) {
 			latest = v
 			latestTime = t
 		}
 	}
-	return latest.Slug
+	return latest.Slug, nil
 }
 
-func (p *upCmd) Execute(_ c.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	clientInit()
+func (p *upCmd) Execute(_ c.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if _, err := clientInit(); err != nil {
+		return exitStatus(err)
+	}
+	set := map[string]bool{}
+	f.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = true
+	})
+	if !set["monitoring"] {
+		p.monitoring = config.Monitoring
+	}
+	if !set["firewall"] {
+		p.firewall = !config.NoFirewall
+	}
+	if p.vpc == "" {
+		p.vpc = config.DefaultVPC
+	}
+	if p.project == "" {
+		p.project = config.DefaultProject
+	}
+	var project *godo.Project
+	if p.project != "" {
+		var err error
+		project, err = findProject(p.project)
+		if err != nil {
+			return exitStatus(err)
+		}
+	}
+	tags := append(append([]string{}, config.DefaultTags...), p.tags...)
+	watches := make([]*watchSpec, len(p.watch))
+	for i, v := range p.watch {
+		w, err := parseWatchSpec(v)
+		if err != nil {
+			println("Unable to watch " + v + ": " + err.Error())
+			return subcommands.ExitUsageError
+		}
+		watches[i] = w
+	}
 	if p.region == "" {
 		p.region = config.DefaultRegion
 	}
 	if p.slug == "" {
 		p.slug = config.DefaultSize
 	}
-	if p.distro == "" {
-		distros, resp, err := client.Images.ListDistribution(context(), &godo.ListOptions{})
+	if p.volume != "" && p.newVolume != 0 {
+		println("Only one of -volume and -new-volume can be used.")
+		return subcommands.ExitUsageError
+	}
+	if p.ipv4 && p.ipv6 {
+		println("Only one of -4 and -6 can be used.")
+		return subcommands.ExitUsageError
+	}
+	if p.private && p.ipv6 {
+		println("-private can't be used with -6 since private network addresses are IPv4.")
+		return subcommands.ExitUsageError
+	}
+	family := 0
+	if p.ipv4 {
+		family = 4
+	} else if p.ipv6 {
+		family = 6
+	}
+	sources := 0
+	for _, v := range []bool{p.distro != "", p.image != "", p.snapshot != "", p.app != "", p.chooseImage} {
+		if v {
+			sources++
+		}
+	}
+	if sources > 1 {
+		println("Only one of -distro, -image, -snapshot, -app and -choose-image can be used.")
+		return subcommands.ExitUsageError
+	}
+	var image godo.DropletCreateImage
+	switch {
+	case p.image != "":
+		image = imageFromFlag(p.image)
+	case p.app != "":
+		image = godo.DropletCreateImage{Slug: p.app}
+	case p.snapshot != "" || p.chooseImage:
+		var id int
+		var err error
+		if p.chooseImage {
+			id, err = chooseImage()
+		} else {
+			id, err = findSnapshot(p.snapshot)
+		}
+		if err != nil {
+			return exitStatus(err)
+		}
+		image = godo.DropletCreateImage{ID: id}
+	case p.distro != "":
+		image = godo.DropletCreateImage{Slug: p.distro}
+	default:
+		distros, _, err := client.Images.ListDistribution(context(), &godo.ListOptions{PerPage: 200})
+		if err != nil {
+			return exitStatus(err)
+		}
+		slug, err := getLatestDistro(distros, p.distroFamily)
+		if err != nil {
+			println(err.Error())
+			return subcommands.ExitUsageError
+		}
+		image = godo.DropletCreateImage{Slug: slug}
+	}
+	// The FreeBSD images don't allow root to log in over SSH, which everything on the droplet is set up with. Snapshots and custom images keep the distribution they were made from.
+	if i, err := getImage(image); err != nil {
+		return exitStatus(err)
+	} else if strings.EqualFold(i.Distribution, "FreeBSD") {
+		println("FreeBSD images can't be used since they don't allow root to log in over SSH.")
+		return subcommands.ExitUsageError
+	}
+	if p.execSSH {
+		if p.record != "" {
+			println("-record can't be used with -exec-ssh since the session doesn't go through do-disposable.")
+			return subcommands.ExitUsageError
+		}
+		if _, err := exec.LookPath("ssh"); err != nil {
+			println("Unable to find the ssh binary: " + err.Error())
+			return subcommands.ExitFailure
+		}
+	}
+	sync, err := getSyncConfig(p.template)
+	if err != nil {
+		println(err.Error())
+		return subcommands.ExitUsageError
+	}
+	env := map[string]string{}
+	for _, v := range p.envFiles {
+		if err := addEnvFile(env, v); err != nil {
+			println(err.Error())
+			return subcommands.ExitUsageError
+		}
+	}
+	for _, v := range p.env {
+		if err := addEnv(env, v); err != nil {
+			println(err.Error())
+			return subcommands.ExitUsageError
+		}
+	}
+	username := ""
+	if p.user {
+		var err error
+		username, err = localUsername()
+		if err != nil {
+			println(err.Error())
+			return subcommands.ExitUsageError
+		}
+	}
+	noTools := !dropletToolsAvailable()
+	if noTools {
+		logger.Warnf("This build of do-disposable has neither the droplet tools embedded nor the digests to download them, so copyfrom and copyback won't be available on the droplet (build with build.sh).")
+	}
+	var rec *recorder
+	if p.record != "" {
+		var err error
+		rec, err = newRecorder(p.record)
 		if err != nil {
-			if resp != nil && resp.StatusCode == 401 {
-				println("Please run do-disposable auth to reset your token.")
-				return subcommands.ExitFailure
-			}
-			panic(err)
+			println(err.Error())
+			return subcommands.ExitFailure
 		}
-		p.distro = getLatestDebian(distros)
 	}
-	handleDisposableDroplet(p.region, p.slug, p.distro)
-	return subcommands.ExitSuccess
+	return exitStatus(handleDisposableDroplet(&dropletOptions{
+		region:         p.region,
+		size:           p.slug,
+		image:          image,
+		watches:        watches,
+		snapshotOnExit: p.snapshotOnExit,
+		volume: volumeOptions{
+			name:         p.volume,
+			newSize:      p.newVolume,
+			deleteOnExit: p.deleteVolume,
+			mountPath:    p.volumeMount,
+		},
+		vpc:        p.vpc,
+		tags:       tags,
+		monitoring: p.monitoring,
+		firewall:   p.firewall,
+		project:    project,
+		family:     family,
+		private:    p.private,
+		record:     rec,
+		execSSH:    p.execSSH,
+		user:       username,
+		sync:       sync,
+		env:        env,
+		noTools:    noTools,
+	}))
 }
@@ -0,0 +1,67 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"os/user"
+	"regexp"
+	"strings"
+)
+
+// The script which creates the user on the droplet.
+//
+//go:embed droplet_user.sh
+var dropletUserScript string
+
+// Defines the usernames which work across the distributions we support.
+var validUsername = regexp.MustCompile("^[a-z_][a-z0-9_-]{0,31}$")
+
+// Used to get the name of the droplet user from the local username.
+func localUsername() (string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	// Windows usernames include the domain.
+	name := u.Username
+	if i := strings.LastIndex(name, "\\"); i != -1 {
+		name = name[i+1:]
+	}
+	name = strings.ToLower(strings.Replace(name, " ", "", -1))
+	if name == "root" {
+		return "", errors.New("your local user is root, so there is no user to create")
+	}
+	if !validUsername.MatchString(name) {
+		return "", errors.New("your local username " + u.Username + " can't be used as a username on the droplet")
+	}
+	return name, nil
+}
+
+// Used to create the user on the droplet with passwordless sudo and the session key. This must be ran as root.
+func createDropletUser(client *ssh.Client, name string) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdin = strings.NewReader(dropletUserScript)
+	out, err := session.CombinedOutput("DROPLET_USER=" + shellQuote(name) + " sh -s")
+	if err != nil {
+		return errors.New("failed to create the user " + name + ": " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
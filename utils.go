@@ -1,17 +1,89 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// Stdin is read in one place so that the droplet session and prompts can take turns reading it without losing input.
+var (
+	stdinOnce     sync.Once
+	stdinStarted  int32
+	stdinChunks   = make(chan []byte)
+	stdinLeftover []byte
+)
+
+// Used to get the chunks read from stdin. The channel is closed when stdin is closed.
+func stdinReader() <-chan []byte {
+	stdinOnce.Do(func() {
+		atomic.StoreInt32(&stdinStarted, 1)
+		go func() {
+			for {
+				b := make([]byte, 4096)
+				n, err := os.Stdin.Read(b)
+				if n > 0 {
+					stdinChunks <- b[:n]
+				}
+				if err != nil {
+					close(stdinChunks)
+					return
+				}
+			}
+		}()
+	})
+	return stdinChunks
+}
+
+// Used to read the next chunk of stdin. Until the reader above is started, stdin is read directly so that no read is left
+// waiting in the background when stdin is handed to another process (such as ssh with -exec-ssh).
+func readStdin() ([]byte, bool) {
+	if atomic.LoadInt32(&stdinStarted) == 1 {
+		b, ok := <-stdinChunks
+		return b, ok
+	}
+	b := make([]byte, 4096)
+	n, err := os.Stdin.Read(b)
+	if n == 0 && err != nil {
+		return nil, false
+	}
+	return b[:n], true
+}
+
 // GetInput is used to get the input which a user types.
 func GetInput(query string) string {
 	print(query)
-	reader := bufio.NewReader(os.Stdin)
-	text, _ := reader.ReadString('\n')
-	text = strings.Replace(text, "\n", "", -1)
+	buf := stdinLeftover
+	stdinLeftover = nil
+	for bytes.IndexByte(buf, '\n') == -1 {
+		b, ok := readStdin()
+		if !ok {
+			break
+		}
+		buf = append(buf, b...)
+	}
+	text := string(buf)
+	if i := strings.IndexByte(text, '\n'); i != -1 {
+		stdinLeftover = buf[i+1:]
+		text = text[:i]
+	}
 	text = strings.Replace(text, "\r", "", -1)
 	return text
 }
+
+// Used to quote a string so that it can be safely passed to a POSIX shell on the droplet.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "'\"'\"'", -1) + "'"
+}
+
+// Defines a flag which can be specified multiple times.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringSliceFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
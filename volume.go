@@ -0,0 +1,148 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"github.com/digitalocean/godo"
+	"golang.org/x/crypto/ssh"
+	"strings"
+)
+
+// Defines the options for the block storage volume attached to the droplet.
+type volumeOptions struct {
+	// The name of an existing volume to attach.
+	name string
+
+	// The size in GiB of a new volume to create. This is 0 if no volume should be created.
+	newSize int64
+
+	// Defines if a new volume is deleted when the droplet is destroyed.
+	deleteOnExit bool
+
+	// The path which the volume is mounted at. Defaults to /mnt/<volume name>.
+	mountPath string
+}
+
+// Used to find an existing volume in a region by name.
+func findVolume(name, region string) (*godo.Volume, error) {
+	volumes, _, err := client.Storage.ListVolumes(context(), &godo.ListVolumeParams{Name: name, Region: region})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes {
+		if v.Name == name {
+			if len(v.DropletIDs) != 0 {
+				return nil, errors.New("the volume " + name + " is already attached to a droplet")
+			}
+			return &v, nil
+		}
+	}
+	return nil, errors.New("can't find a volume named " + name + " in " + region)
+}
+
+// Used to create a new volume for the droplet. The volume is formatted as ext4 by DigitalOcean.
+func createVolume(name, region string, size int64) (*godo.Volume, error) {
+	print("Creating volume " + name + "... ")
+	v, _, err := client.Storage.CreateVolume(context(), &godo.VolumeCreateRequest{
+		Region:         region,
+		Name:           name,
+		Description:    "Created by do-disposable.",
+		SizeGigaBytes:  size,
+		FilesystemType: "ext4",
+		Tags:           []string{"do-disposable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	println("done!")
+	return v, nil
+}
+
+// Used to attach the volume to the droplet and wait for the attach action.
+func attachVolume(v *godo.Volume, dropletID int) error {
+	print("Attaching volume " + v.Name + "... ")
+	a, _, err := client.StorageActions.Attach(context(), v.ID, dropletID)
+	if err != nil {
+		return err
+	}
+	err = waitForVolumeAction(v.ID, a.ID)
+	if err != nil {
+		return err
+	}
+	println("done!")
+	return nil
+}
+
+// Used to mount the volume on the droplet. If the volume has no filesystem, it is formatted as ext4 first.
+func mountVolume(client *ssh.Client, v *godo.Volume, mountPath string) error {
+	dev := shellQuote("/dev/disk/by-id/scsi-0DO_Volume_" + v.Name)
+	script := `set -e
+if [ "$(uname)" != 'Linux' ]; then
+  echo "Volumes can only be mounted on Linux droplets." 1>&2
+  exit 1
+fi
+i=0
+while [ ! -e ` + dev + ` ] && [ $i -lt 30 ]; do
+  sleep 1
+  i=$((i+1))
+done
+if ! blkid ` + dev + ` > /dev/null 2>&1; then
+  mkfs.ext4 -q ` + dev + `
+fi
+mkdir -p ` + shellQuote(mountPath) + `
+mount -o defaults,nofail,discard,noatime ` + dev + ` ` + shellQuote(mountPath) + `
+`
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdin = strings.NewReader(script)
+	out, err := session.CombinedOutput("sh -s")
+	if err != nil {
+		return errors.New("failed to mount the volume: " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Used to give the session user the mount point of the volume so that they can write to it.
+func chownVolume(client *ssh.Client, mountPath, user string) error {
+	q := shellQuote(user)
+	out, err := remoteOutput(client, "chown "+q+":\"$(id -gn "+q+")\" "+shellQuote(mountPath)+" 2>&1")
+	if err != nil {
+		return errors.New("failed to give " + user + " the volume: " + out)
+	}
+	return nil
+}
+
+// Used to cleanly unmount (if possible) and detach the volume before the droplet is destroyed. Errors are logged since this is ran during teardown.
+func detachVolume(sshClient *ssh.Client, v *godo.Volume, mountPath string, dropletID int) {
+	if sshClient != nil {
+		session, err := sshClient.NewSession()
+		if err == nil {
+			_ = session.Run("sync && umount " + shellQuote(mountPath))
+			_ = session.Close()
+		}
+	}
+	print("Detaching volume " + v.Name + "... ")
+	a, _, err := client.StorageActions.DetachByDropletID(context(), v.ID, dropletID)
+	if err == nil {
+		err = waitForVolumeAction(v.ID, a.ID)
+	}
+	if err != nil {
+		logger.Errorf("Failed to detach the volume: %v", err)
+		return
+	}
+	println("done!")
+}
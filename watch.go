@@ -0,0 +1,287 @@
+// Copyright 2020 DigitalOcean
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//		http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Defines a host directory which is watched and pushed to the droplet.
+type watchSpec struct {
+	LocalDir  string
+	RemoteDir string
+}
+
+// Used to parse a watch flag in the format <dir>[:<remote dir>].
+func parseWatchSpec(s string) (*watchSpec, error) {
+	localDir := s
+	remoteDir := ""
+	if i := strings.LastIndex(s, ":"); i != -1 && !(runtime.GOOS == "windows" && i == 1) {
+		localDir = s[:i]
+		remoteDir = s[i+1:]
+	}
+	if localDir == "" {
+		return nil, errors.New("no directory given to watch")
+	}
+	localDir, err := filepath.Abs(localDir)
+	if err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(localDir)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		return nil, errors.New(localDir + " is not a directory")
+	}
+	if remoteDir == "" {
+		// Relative paths are relative to the home directory of the droplet user.
+		remoteDir = filepath.Base(localDir)
+	}
+	return &watchSpec{LocalDir: localDir, RemoteDir: remoteDir}, nil
+}
+
+// Used to get the droplet path for a host path within the watched directory.
+func (w *watchSpec) remotePath(localPath string) string {
+	rel, err := filepath.Rel(w.LocalDir, localPath)
+	if err != nil || rel == "." {
+		return w.RemoteDir
+	}
+	return path.Join(w.RemoteDir, filepath.ToSlash(rel))
+}
+
+// Used to run a command on the droplet in a new session.
+func runRemote(client *ssh.Client, cmd string, stdin *os.File) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	if stdin != nil {
+		session.Stdin = stdin
+	}
+	return session.Run(cmd)
+}
+
+// Used to push a single file to the droplet. The file is written to a temporary path first so that a partially written file is never seen.
+func pushFile(client *ssh.Client, localPath, remotePath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := shellQuote(remotePath + ".do-disposable-tmp")
+	cmd := fmt.Sprintf("mkdir -p %s && cat > %s && chmod %o %s && mv -f %s %s",
+		shellQuote(path.Dir(remotePath)), tmp, s.Mode().Perm(), tmp, tmp, shellQuote(remotePath))
+	return runRemote(client, cmd, f)
+}
+
+// Returned when the droplet has no tar to extract a pushed archive with.
+var errNoRemoteTar = errors.New("tar is not installed on the droplet")
+
+// Used to add a host path to a tar archive as name. Folders are added recursively and anything which isn't a regular file or folder is skipped.
+func addToTar(tw *tar.Writer, localPath, name string) error {
+	return filepath.Walk(localPath, func(p string, s os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !s.IsDir() && !s.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		h, err := tar.FileInfoHeader(s, "")
+		if err != nil {
+			return err
+		}
+		h.Name = path.Join(name, filepath.ToSlash(rel))
+		if s.IsDir() {
+			h.Name += "/"
+		}
+
+		// The files belong to whoever extracts them on the droplet.
+		h.Uid, h.Gid, h.Uname, h.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(h); err != nil || s.IsDir() {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.CopyN(tw, f, h.Size)
+		return err
+	})
+}
+
+// Used to stream a tar archive to the droplet in a single session and extract it into a remote folder.
+func pushTar(client *ssh.Client, remoteDir string, write func(tw *tar.Writer) error) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	q := shellQuote(remoteDir)
+	err = session.Start("command -v tar > /dev/null || exit 127; mkdir -p " + q + " && tar -x -f - -C " + q)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(stdin)
+	werr := write(tw)
+	if werr == nil {
+		werr = tw.Close()
+	}
+	_ = stdin.Close()
+	err = session.Wait()
+	if exitErr, ok := err.(*ssh.ExitError); ok && exitErr.ExitStatus() == 127 {
+		return errNoRemoteTar
+	}
+	if werr != nil {
+		return werr
+	}
+	if err != nil {
+		return errors.New("failed to extract the files on the droplet: " + strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Used to push a host path to the droplet. Folders are pushed recursively and paths which no longer exist are removed.
+func (w *watchSpec) push(client *ssh.Client, localPath string) error {
+	s, err := os.Lstat(localPath)
+	if os.IsNotExist(err) {
+		return runRemote(client, "rm -rf "+shellQuote(w.remotePath(localPath)), nil)
+	} else if err != nil {
+		return err
+	}
+	if s.IsDir() {
+		// Stream the folder in one session. Images without tar get one session per file instead.
+		err = pushTar(client, w.remotePath(localPath), func(tw *tar.Writer) error {
+			return addToTar(tw, localPath, ".")
+		})
+		if err != errNoRemoteTar {
+			return err
+		}
+		return filepath.Walk(localPath, func(p string, s os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if s.IsDir() {
+				return runRemote(client, "mkdir -p "+shellQuote(w.remotePath(p)), nil)
+			}
+			if !s.Mode().IsRegular() {
+				return nil
+			}
+			return pushFile(client, p, w.remotePath(p))
+		})
+	}
+	if !s.Mode().IsRegular() {
+		// Ignore sockets, pipes and links.
+		return nil
+	}
+	return pushFile(client, localPath, w.remotePath(localPath))
+}
+
+// Used to add a folder and all of its subfolders to the watcher.
+func watchRecursive(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(p string, s os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if s.IsDir() {
+			return watcher.Add(p)
+		}
+		return nil
+	})
+}
+
+// Used to push the watched directory to the droplet and then keep pushing any changes as they are saved.
+// Changes are batched for a short period so that editors which write a file in several steps only cause one push.
+func watchAndPush(client *ssh.Client, w *watchSpec) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	err = watchRecursive(watcher, w.LocalDir)
+	if err != nil {
+		return err
+	}
+
+	// Do the initial push.
+	err = w.push(client, w.LocalDir)
+	if err != nil {
+		return err
+	}
+
+	// Handle the events.
+	pending := map[string]struct{}{}
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		select {
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Op&fsnotify.Create != 0 {
+				if s, err := os.Lstat(ev.Name); err == nil && s.IsDir() {
+					_ = watchRecursive(watcher, ev.Name)
+				}
+			}
+			if ev.Op == fsnotify.Chmod {
+				// Permission changes alone are not worth a push.
+				continue
+			}
+			pending[ev.Name] = struct{}{}
+			timer.Reset(200 * time.Millisecond)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-timer.C:
+			for p := range pending {
+				if err := w.push(client, p); err != nil {
+					logger.Warnf("Failed to push %s to the droplet: %v", p, err)
+				} else {
+					logger.Debugf("Pushed %s to the droplet.", p)
+				}
+			}
+			pending = map[string]struct{}{}
+		}
+	}
+}